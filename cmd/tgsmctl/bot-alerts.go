@@ -0,0 +1,104 @@
+package main
+
+import (
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/covrom/smart-control/internal/api"
+	"github.com/covrom/smart-control/internal/i18n"
+	"github.com/covrom/smart-control/internal/store"
+	"github.com/covrom/smart-control/internal/tghtml"
+	tele "gopkg.in/telebot.v3"
+)
+
+// snoozePeriod — на сколько кнопка «Отложить» отключает сообщения о проблеме
+const snoozePeriod = 7 * 24 * time.Hour
+
+// registerAlertButtons устанавливает обработчики кнопок уведомлений об устройствах
+func (bc *botCommands) registerAlertButtons(b *tele.Bot) {
+	b.Handle(&api.BtnAck, bc.alertButton(func(a *store.AlertState, who string, now time.Time, l i18n.Lang) string {
+		a.AckedBy, a.AckedAt = who, now
+		return l.Sprintf("button.acked", who)
+	}))
+	b.Handle(&api.BtnSnooze, bc.alertButton(func(a *store.AlertState, who string, now time.Time, l i18n.Lang) string {
+		a.SnoozedUntil = now.Add(snoozePeriod)
+		return l.Sprintf("button.snoozed", l.DateTime(a.SnoozedUntil), who)
+	}))
+	b.Handle(&api.BtnMute, bc.alertButton(func(a *store.AlertState, who string, now time.Time, l i18n.Lang) string {
+		a.Muted, a.MutedBy = true, who
+		return l.Sprintf("button.muted", who)
+	}))
+	b.Handle(&api.BtnUnmute, bc.alertButton(func(a *store.AlertState, who string, now time.Time, l i18n.Lang) string {
+		a.Muted, a.MutedBy = false, ""
+		return l.Sprintf("button.unmuted", who)
+	}))
+	b.Handle(&api.BtnRaw, bc.rawData)
+}
+
+// senderName — кто нажал кнопку
+func senderName(c tele.Context) string {
+	u := c.Sender()
+	if u == nil {
+		return "?"
+	}
+	if u.Username != "" {
+		return "@" + u.Username
+	}
+	return strings.TrimSpace(u.FirstName + " " + u.LastName)
+}
+
+// alertDevice находит устройство уведомления по данным кнопки
+func (bc *botCommands) alertDevice(c tele.Context) (host, device string, ok bool) {
+	host, device, ok = api.ParseAlertData(c.Data())
+	if !ok {
+		return "", "", false
+	}
+	h, found, err := bc.st.Host(host)
+	if err != nil {
+		slog.Error("telegram button: load host", "host", host, "err", err)
+	}
+	return host, device, found && slices.Contains(h.Devices, device)
+}
+
+// alertButton изменяет состояние оповещений устройства функцией fn и дописывает
+// в исходное сообщение на языке чата, кто и что сделал
+func (bc *botCommands) alertButton(fn func(a *store.AlertState, who string, now time.Time, l i18n.Lang) string) tele.HandlerFunc {
+	return func(c tele.Context) error {
+		l := bc.lang(c)
+		host, device, ok := bc.alertDevice(c)
+		if !ok {
+			return c.Respond(&tele.CallbackResponse{Text: l.T("device.not_found")})
+		}
+
+		var note string
+		a, err := bc.st.UpdateAlert(host, device, func(a *store.AlertState) {
+			note = fn(a, senderName(c), time.Now(), l)
+		})
+		if err != nil {
+			slog.Error("telegram button: update alert", "host", host, "device", device, "err", err)
+			return c.Respond(&tele.CallbackResponse{Text: l.Sprintf("error", err.Error())})
+		}
+		slog.Info("alert state changed", "host", host, "device", device, "by", senderName(c), "action", c.Callback().Unique)
+
+		if msg := c.Message(); msg != nil {
+			mark := note + ", " + l.DateTime(time.Now())
+			markup := api.AlertMarkup(host, device, a.Muted, l)
+			var err error
+			if tghtml.TextLen(msg.Text)+tghtml.TextLen("\n\n"+mark) <= tghtml.MaxMessageLen {
+				// разметку исходного сообщения сохраняем через его entities:
+				// отметка дописывается в конец и смещения не меняет
+				err = c.Edit(msg.Text+"\n\n"+mark, &tele.SendOptions{Entities: msg.Entities, ReplyMarkup: markup})
+			} else if _, err = c.Bot().EditReplyMarkup(msg, markup); err == nil {
+				// отметка не помещается в сообщение: обновляем только кнопки,
+				// а отметку отправляем ответом на него
+				err = c.Reply(mark)
+			}
+			if err != nil {
+				slog.Error("telegram button: edit message", "err", err)
+			}
+		}
+		return c.Respond(&tele.CallbackResponse{Text: note})
+	}
+}
@@ -0,0 +1,494 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/covrom/smart-control/internal/i18n"
+	"github.com/covrom/smart-control/internal/llmdesc"
+	"github.com/covrom/smart-control/internal/msgtmpl"
+	"github.com/covrom/smart-control/internal/route"
+	"github.com/covrom/smart-control/internal/smartdata"
+	"github.com/covrom/smart-control/internal/store"
+	"github.com/covrom/smart-control/internal/tghtml"
+	tele "gopkg.in/telebot.v3"
+)
+
+const (
+	// defaultHistoryPeriod — период /history, если он не указан
+	defaultHistoryPeriod = 30 * 24 * time.Hour
+	// maxHistoryLines — сколько снимков выводить в ответе /history
+	maxHistoryLines = 30
+)
+
+// botMetrics — показатели в ответах бота с короткими подписями
+var botMetrics = []struct{ name, label, unit string }{
+	{smartdata.MetricTemperature, "t", "°C"},
+	{smartdata.MetricReallocated, "realloc", ""},
+	{smartdata.MetricPending, "pending", ""},
+	{smartdata.MetricOfflineUncorr, "uncorr", ""},
+	{smartdata.MetricGrownDefects, "defects", ""},
+	{smartdata.MetricMediaErrors, "media err", ""},
+	{smartdata.MetricPercentageUsed, "used", "%"},
+	{smartdata.MetricAvailableSpare, "spare", "%"},
+}
+
+// botCommands — команды бота, отвечающие по данным хранилища сервера
+type botCommands struct {
+	ctx          context.Context
+	st           *store.Store
+	llmDescriber *llmdesc.LLMSmartDescriber
+	out          *outbox         // шаблоны сообщений и правила маршрутизации с языками чатов
+	reports      *reportReceiver // оповещения по заключению /analyze — как по отчётам
+	allowed      []int64         // чаты, которым разрешены команды
+}
+
+// lang — язык ответов в чате: из правил маршрутизации, иначе язык сервера
+func (bc *botCommands) lang(c tele.Context) i18n.Lang {
+	def := bc.out.tmpl.Lang()
+	if c.Chat() == nil {
+		return def
+	}
+	return route.ChatLang(bc.out.routes, c.Chat().ID, def)
+}
+
+// parseChatIDs разбирает список ID чатов Telegram
+func parseChatIDs(ids ...string) ([]int64, error) {
+	var ret []int64
+	for _, s := range ids {
+		if s == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid chat id %q: %w", s, err)
+		}
+		if !slices.Contains(ret, id) {
+			ret = append(ret, id)
+		}
+	}
+	return ret, nil
+}
+
+// restrict пропускает обновления только из разрешённых чатов. Проверяется
+// чат, а не отправитель: middleware.Restrict сравнивает ID пользователя,
+// а уведомления и команды живут в группах.
+func (bc *botCommands) restrict(next tele.HandlerFunc) tele.HandlerFunc {
+	return func(c tele.Context) error {
+		if chat := c.Chat(); chat != nil && slices.Contains(bc.allowed, chat.ID) {
+			return next(c)
+		}
+		var chatID int64
+		if c.Chat() != nil {
+			chatID = c.Chat().ID
+		}
+		slog.Warn("telegram: unauthorized chat", "chat", chatID, "text", c.Text())
+		// без ответа на нажатие кнопки Telegram показывает ожидание до тайм-аута
+		if c.Callback() != nil {
+			return c.Respond(&tele.CallbackResponse{Text: bc.out.tmpl.Lang().T("chat.not_allowed")})
+		}
+		return nil
+	}
+}
+
+// register устанавливает обработчики команд. Команды из чатов не из списка
+// разрешённых игнорируются.
+func (bc *botCommands) register(b *tele.Bot) {
+	b.Use(bc.restrict)
+
+	// описания команд и аргументов — ключи каталога i18n
+	commands := []struct {
+		cmd, args string
+		h         tele.HandlerFunc
+	}{
+		{"status", "", bc.status},
+		{"hosts", "", bc.hosts},
+		{"host", "cmd.host.args", bc.host},
+		{"device", "cmd.device.args", bc.device},
+		{"history", "cmd.history.args", bc.history},
+		{"raw", "cmd.device.args", bc.raw},
+		{"analyze", "cmd.device.args", bc.analyze},
+	}
+
+	// справка и меню команд общие для всех чатов, поэтому на языке сервера
+	l := bc.out.tmpl.Lang()
+	var help strings.Builder
+	help.WriteString(l.T("cmd.help") + "\n")
+	var menu []tele.Command
+	for _, c := range commands {
+		b.Handle("/"+c.cmd, c.h)
+		var args string
+		if c.args != "" {
+			args = l.T(c.args)
+		}
+		desc := l.T("cmd." + c.cmd)
+		fmt.Fprintf(&help, "/%s %s — %s\n", c.cmd, args, desc)
+		menu = append(menu, tele.Command{Text: c.cmd, Description: desc})
+	}
+	helpText := help.String()
+	b.Handle("/start", func(c tele.Context) error { return c.Send(helpText) })
+	b.Handle("/help", func(c tele.Context) error { return c.Send(helpText) })
+
+	bc.registerAlertButtons(b)
+
+	if err := b.SetCommands(menu); err != nil {
+		slog.Error("telegram: set commands", "err", err)
+	}
+}
+
+// reply отправляет ответ в разметке HTML, разбивая длинный текст на части
+func reply(c tele.Context, html string) error {
+	for _, part := range tghtml.Split(html, tghtml.MaxMessageLen) {
+		if err := c.Send(part, tele.ModeHTML); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// htmlf дописывает в b текст, отформатированный tghtml.Sprintf
+func htmlf(b *strings.Builder, format string, args ...any) {
+	b.WriteString(tghtml.Sprintf(format, args...))
+}
+
+func (bc *botCommands) status(c tele.Context) error {
+	now := time.Now()
+	data, err := digestData(bc.st, now.Add(-24*time.Hour), now)
+	if err != nil {
+		return bc.fail(c, err)
+	}
+	text, err := bc.out.tmpl.Render(bc.lang(c), route.EventDigest, data)
+	if err != nil {
+		return bc.fail(c, err)
+	}
+	return reply(c, text)
+}
+
+func (bc *botCommands) hosts(c tele.Context) error {
+	l := bc.lang(c)
+	hosts, err := bc.st.Hosts()
+	if err != nil {
+		return bc.fail(c, err)
+	}
+	if len(hosts) == 0 {
+		return c.Send(l.T("hosts.none"))
+	}
+	var b strings.Builder
+	for _, h := range hosts {
+		devices, err := bc.st.DeviceSummaries(h)
+		if err != nil {
+			return bc.fail(c, err)
+		}
+		worst := store.LevelUnknown
+		for _, sum := range devices {
+			if lvl, _ := h.DeviceLevel(sum); lvl.Rank() > worst.Rank() {
+				worst = lvl
+			}
+		}
+		htmlf(&b, l.T("hosts.line"), worst.Emoji(), h.Hostname, h.OS, len(h.Devices), l.DateTime(h.LastReport))
+		if !h.MissingSince.IsZero() {
+			b.WriteString(" ⏰")
+		}
+		if h.RawError != "" {
+			b.WriteString(" ❌")
+		}
+		b.WriteString("\n")
+	}
+	return reply(c, b.String())
+}
+
+func (bc *botCommands) host(c tele.Context) error {
+	l := bc.lang(c)
+	args := c.Args()
+	if len(args) != 1 {
+		return c.Send(l.T("host.usage"))
+	}
+	h, ok, err := bc.st.Host(args[0])
+	if err != nil {
+		return bc.fail(c, err)
+	}
+	if !ok {
+		return c.Send(l.Sprintf("host.not_found", args[0]))
+	}
+	devices, err := bc.st.DeviceSummaries(h)
+	if err != nil {
+		return bc.fail(c, err)
+	}
+
+	var b strings.Builder
+	htmlf(&b, l.T("host.header"), h.Hostname, h.OS, h.AgentVersion, l.DateTime(h.LastReport))
+	if !h.MissingSince.IsZero() {
+		htmlf(&b, l.T("host.missing"), l.DateTime(h.MissingSince))
+	}
+	if h.ErrorCode != "" {
+		htmlf(&b, "❌ %s\n", l.T("error."+h.ErrorCode))
+	}
+	if h.RawError != "" {
+		htmlf(&b, "<pre>%s</pre>\n", h.RawError)
+	}
+	b.WriteString("\n")
+	for _, sum := range devices {
+		lvl, _ := h.DeviceLevel(sum)
+		htmlf(&b, "%s <code>%s</code>", lvl.Emoji(), sum.ID)
+		if sum.Model != "" {
+			htmlf(&b, " — %s", sum.Model)
+		}
+		if m := formatMetrics(sum.Values); m != "" {
+			b.WriteString(", " + tghtml.Escape(m))
+		}
+		b.WriteString("\n")
+	}
+	return reply(c, b.String())
+}
+
+func (bc *botCommands) device(c tele.Context) error {
+	l := bc.lang(c)
+	host, device, _, err := bc.resolveDevice(c.Args(), l)
+	if err != nil {
+		return c.Send(err.Error())
+	}
+	snap, ok, err := bc.st.Latest(host, device)
+	if err != nil {
+		return bc.fail(c, err)
+	}
+	if !ok {
+		return c.Send(l.T("device.no_snapshot"))
+	}
+	return reply(c, formatSnapshot(snap, l))
+}
+
+func (bc *botCommands) history(c tele.Context) error {
+	l := bc.lang(c)
+	host, device, rest, err := bc.resolveDevice(c.Args(), l)
+	if err != nil {
+		return c.Send(err.Error())
+	}
+	period := defaultHistoryPeriod
+	if len(rest) > 0 {
+		if period, err = parsePeriod(rest[0], l); err != nil {
+			return c.Send(err.Error())
+		}
+	}
+
+	now := time.Now()
+	history, err := bc.st.History(host, device, now.Add(-period), time.Time{})
+	if err != nil {
+		return bc.fail(c, err)
+	}
+	if len(history) == 0 {
+		return c.Send(l.T("history.empty"))
+	}
+
+	var b strings.Builder
+	htmlf(&b, l.T("history.header"), host, device, rest0(rest, "30d"), len(history))
+	shown := history[:min(len(history), maxHistoryLines)]
+	for i := len(shown) - 1; i >= 0; i-- {
+		snap := shown[i]
+		htmlf(&b, "%s %s %s\n", l.ShortDateTime(snap.Timestamp), snap.Analysis.Verdict().Emoji(),
+			formatMetrics(snap.Metrics.Values))
+	}
+	if len(history) > len(shown) {
+		htmlf(&b, l.T("history.truncated"), len(shown))
+	}
+
+	first, last := history[len(history)-1], history[0]
+	var changes []string
+	for _, m := range digestDeltaMetrics {
+		cur, ok1 := last.Metrics.Values[m]
+		old, ok2 := first.Metrics.Values[m]
+		if ok1 && ok2 && cur != old {
+			changes = append(changes, fmt.Sprintf("%s %+g", m, cur-old))
+		}
+	}
+	if len(changes) > 0 {
+		htmlf(&b, l.T("history.changes"), strings.Join(changes, ", "))
+	} else {
+		b.WriteString(l.T("history.unchanged"))
+	}
+	if err := reply(c, b.String()); err != nil {
+		return err
+	}
+
+	png, err := trendChart(bc.st, host, device, now.Add(-period), now)
+	if err != nil {
+		return bc.fail(c, err)
+	}
+	if png == nil {
+		return nil
+	}
+	return c.Send(&tele.Photo{File: tele.FromReader(bytes.NewReader(png))})
+}
+
+func (bc *botCommands) analyze(c tele.Context) error {
+	l := bc.lang(c)
+	host, device, _, err := bc.resolveDevice(c.Args(), l)
+	if err != nil {
+		return c.Send(err.Error())
+	}
+	snap, ok, err := bc.st.Latest(host, device)
+	if err != nil {
+		return bc.fail(c, err)
+	}
+	if !ok || snap.Device.SMARTData == "" {
+		return c.Send(l.T("analyze.no_data"))
+	}
+	prev, _, err := bc.st.Previous(host, device, snap.Timestamp)
+	if err != nil {
+		return bc.fail(c, err)
+	}
+
+	if err := c.Send(l.T("analyze.running")); err != nil {
+		return err
+	}
+	analysis := bc.llmDescriber.Describe(bc.ctx, host, snap.Device, prev.Device)
+	if analysis == nil {
+		return c.Send(l.T("analyze.failed"))
+	}
+	// новое заключение повторяется для следующих снимков без изменений
+	analysis.Fingerprint = snap.Metrics.Fingerprint()
+	snap.Analysis = analysis
+	if err := bc.st.SaveSnapshot(snap); err != nil {
+		return bc.fail(c, err)
+	}
+	// смена уровня по новому заключению проходит машину состояний оповещений,
+	// иначе она учлась бы только со следующим отчётом агента
+	h, _, err := bc.st.Host(host)
+	if err != nil {
+		return bc.fail(c, err)
+	}
+	report := smartdata.CommonSMARTReport{Hostname: host, OS: h.OS, Timestamp: snap.Timestamp, Labels: h.Labels}
+	key := fmt.Sprintf("analyze/%s/%s/%d", host, store.DeviceID(device), time.Now().UnixNano())
+	if err := bc.reports.alertDevice(report, snap.Device, snap, bc.reports.publisher(key, report)); err != nil {
+		return bc.fail(c, err)
+	}
+	return reply(c, formatSnapshot(snap, l))
+}
+
+// fail сообщает пользователю о внутренней ошибке
+func (bc *botCommands) fail(c tele.Context, err error) error {
+	slog.Error("telegram command", "text", c.Text(), "err", err)
+	return c.Send(bc.lang(c).Sprintf("error", err.Error()))
+}
+
+// resolveDevice находит устройство по аргументам "<хост> <диск> ..." или
+// "<диск> ...", если диск с таким именем есть только на одном хосте.
+// Возвращает оставшиеся аргументы. Ошибки для пользователя — на языке l.
+func (bc *botCommands) resolveDevice(args []string, l i18n.Lang) (host, device string, rest []string, err error) {
+	if len(args) == 0 {
+		return "", "", nil, errors.New(l.T("device.usage"))
+	}
+	if len(args) >= 2 {
+		device, ok, err := bc.st.FindDevice(args[0], args[1])
+		if err != nil {
+			return "", "", nil, err
+		}
+		if ok {
+			return args[0], device, args[2:], nil
+		}
+	}
+
+	hosts, err := bc.st.Hosts()
+	if err != nil {
+		return "", "", nil, err
+	}
+	var found []string
+	for _, h := range hosts {
+		d, ok, err := bc.st.FindDevice(h.Hostname, args[0])
+		if err != nil {
+			return "", "", nil, err
+		}
+		if ok {
+			host, device = h.Hostname, d
+			found = append(found, h.Hostname)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return "", "", nil, errors.New(l.Sprintf("device.unknown", strings.Join(args, " ")))
+	case 1:
+		return host, device, args[1:], nil
+	}
+	return "", "", nil, errors.New(l.Sprintf("device.ambiguous", args[0], strings.Join(found, ", ")))
+}
+
+// parsePeriod разбирает период вида 30d, 2w или длительность Go (12h);
+// ошибка — на языке l
+func parsePeriod(s string, l i18n.Lang) (time.Duration, error) {
+	unit := time.Duration(0)
+	switch {
+	case strings.HasSuffix(s, "d"):
+		unit = 24 * time.Hour
+	case strings.HasSuffix(s, "w"):
+		unit = 7 * 24 * time.Hour
+	}
+	if unit != 0 {
+		n, err := strconv.Atoi(s[:len(s)-1])
+		if err != nil || n <= 0 {
+			return 0, errors.New(l.Sprintf("period.invalid", s))
+		}
+		return time.Duration(n) * unit, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, errors.New(l.Sprintf("period.invalid", s))
+	}
+	return d, nil
+}
+
+func rest0(rest []string, def string) string {
+	if len(rest) > 0 {
+		return rest[0]
+	}
+	return def
+}
+
+// formatMetrics — основные показатели устройства в одну строку
+func formatMetrics(values map[string]float64) string {
+	var parts []string
+	for _, m := range botMetrics {
+		if v, ok := values[m.name]; ok {
+			parts = append(parts, fmt.Sprintf("%s %g%s", m.label, v, m.unit))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatSnapshot — состояние устройства по снимку в разметке HTML на языке l
+func formatSnapshot(snap store.Snapshot, l i18n.Lang) string {
+	var b strings.Builder
+	m := snap.Metrics
+	htmlf(&b, "📀 <b>%s %s</b>\n", snap.Hostname, snap.Device.Device)
+	if m.Model != "" {
+		htmlf(&b, l.T("snapshot.model"), m.Model)
+	}
+	if m.Serial != "" {
+		htmlf(&b, "S/N: <code>%s</code>\n", m.Serial)
+	}
+	if m.Firmware != "" {
+		htmlf(&b, l.T("snapshot.firmware"), m.Firmware)
+	}
+	if m.Health != "" {
+		htmlf(&b, "SMART: %s\n", m.Health)
+	}
+	if len(snap.Device.MountPaths) > 0 {
+		htmlf(&b, l.T("snapshot.mounts"), strings.Join(snap.Device.MountPaths, ", "))
+	}
+	htmlf(&b, l.T("snapshot.time"), l.DateTime(snap.Timestamp))
+	if s := formatMetrics(m.Values); s != "" {
+		b.WriteString(tghtml.Escape(s) + "\n")
+	}
+	if snap.Analysis != nil {
+		b.WriteString("\n" + tghtml.Markdown(snap.Analysis.Text))
+	}
+	if table := msgtmpl.MetricsTable(m, l); table != "" {
+		b.WriteString("\n\n" + tghtml.Expandable(table))
+	}
+	return b.String()
+}
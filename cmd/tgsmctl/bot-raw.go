@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/covrom/smart-control/internal/i18n"
+	"github.com/covrom/smart-control/internal/store"
+	"github.com/covrom/smart-control/internal/textdiff"
+	tele "gopkg.in/telebot.v3"
+)
+
+// rawDiffWidth — ширина колонки в файле сравнения выводов smartctl
+const rawDiffWidth = 90
+
+// rawData — кнопка «Сырые данные» уведомления
+func (bc *botCommands) rawData(c tele.Context) error {
+	l := bc.lang(c)
+	host, device, ok := bc.alertDevice(c)
+	if !ok {
+		return c.Respond(&tele.CallbackResponse{Text: l.T("device.not_found")})
+	}
+	if err := bc.sendRaw(c, host, device); err != nil {
+		slog.Error("telegram button: send raw data", "err", err)
+		return c.Respond(&tele.CallbackResponse{Text: l.Sprintf("error", err.Error())})
+	}
+	return c.Respond()
+}
+
+// raw — команда /raw [хост] <диск>
+func (bc *botCommands) raw(c tele.Context) error {
+	host, device, _, err := bc.resolveDevice(c.Args(), bc.lang(c))
+	if err != nil {
+		return c.Send(err.Error())
+	}
+	if err := bc.sendRaw(c, host, device); err != nil {
+		return bc.fail(c, err)
+	}
+	return nil
+}
+
+// sendRaw отправляет файлами вывод smartctl последнего и предыдущего снимков
+// устройства и их сравнение в две колонки, чтобы проверить заключение LLM
+// без входа на хост
+func (bc *botCommands) sendRaw(c tele.Context, host, device string) error {
+	l := bc.lang(c)
+	snap, ok, err := bc.st.Latest(host, device)
+	if err != nil {
+		return err
+	}
+	if !ok || snap.Device.SMARTData == "" {
+		return c.Send(l.T("raw.no_data"))
+	}
+	if err := c.Send(rawDocument(host, device, snap, "smartctl", l)); err != nil {
+		return err
+	}
+
+	prev, ok, err := bc.st.Previous(host, device, snap.Timestamp)
+	if err != nil {
+		return err
+	}
+	if !ok || prev.Device.SMARTData == "" {
+		return c.Send(l.T("raw.no_prev"))
+	}
+	if err := c.Send(rawDocument(host, device, prev, "smartctl_prev", l)); err != nil {
+		return err
+	}
+	if !textdiff.Changed(prev.Device.SMARTData, snap.Device.SMARTData) {
+		return c.Send(l.T("raw.unchanged"))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-*s   %s\n\n", rawDiffWidth, l.DateTime(prev.Timestamp), l.DateTime(snap.Timestamp))
+	b.WriteString(textdiff.SideBySide(prev.Device.SMARTData, snap.Device.SMARTData, rawDiffWidth))
+	return c.Send(&tele.Document{
+		File:     tele.FromReader(strings.NewReader(b.String())),
+		FileName: fmt.Sprintf("%s_%s_smartctl_diff.txt", host, store.DeviceID(device)),
+		Caption:  l.Sprintf("raw.diff", host, device, l.DateTime(prev.Timestamp), l.DateTime(snap.Timestamp)),
+	})
+}
+
+// rawDocument — вывод smartctl снимка файлом .txt
+func rawDocument(host, device string, snap store.Snapshot, suffix string, l i18n.Lang) *tele.Document {
+	return &tele.Document{
+		File:     tele.FromReader(strings.NewReader(snap.Device.SMARTData)),
+		FileName: fmt.Sprintf("%s_%s_%s.txt", host, store.DeviceID(device), suffix),
+		Caption:  fmt.Sprintf("smartctl %s %s, %s", host, device, l.DateTime(snap.Timestamp)),
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/covrom/smart-control/internal/queue"
+)
+
+const defaultDataDir = "/var/lib/smart_reports_data"
+
+func dataDirFromEnv() string {
+	if dir := os.Getenv("DATA_DIR"); dir != "" {
+		return dir
+	}
+	return defaultDataDir
+}
+
+// splitList разбирает список значений переменной окружения, разделённых запятыми
+func splitList(s string) []string {
+	var ret []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			ret = append(ret, v)
+		}
+	}
+	return ret
+}
+
+// openQueues открывает очереди анализа отчётов, отправки сообщений Telegram
+// и доставки уведомлений в дополнительные каналы
+func openQueues(dataDir string) (reports, messages, notifications *queue.Queue, err error) {
+	reports, err = queue.Open(filepath.Join(dataDir, "queue", "reports"), queue.Policy{
+		MaxAttempts: 5,
+		MinBackoff:  time.Minute,
+		MaxBackoff:  time.Hour,
+		KeepDone:    queue.DefaultPolicy.KeepDone,
+
+		PostponesPerAttempt: queue.DefaultPolicy.PostponesPerAttempt,
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	messages, err = queue.Open(filepath.Join(dataDir, "queue", "messages"), queue.DefaultPolicy)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	notifications, err = queue.Open(filepath.Join(dataDir, "queue", "notifications"), queue.DefaultPolicy)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return reports, messages, notifications, nil
+}
+
+func cliUsage() {
+	fmt.Fprint(os.Stderr, `Использование:
+  tgsmctl queue list [-dead] reports|messages|notifications
+  tgsmctl queue show reports|messages|notifications <id>
+  tgsmctl queue requeue reports|messages|notifications <id>|all
+
+Без аргументов запускается в режиме, заданном переменной MODE.
+`)
+}
+
+// runCLI выполняет команду командной строки и возвращает код завершения
+func runCLI(args []string) int {
+	if args[0] != "queue" || len(args) < 2 {
+		cliUsage()
+		return 2
+	}
+	return runQueueCLI(args[1], args[2:])
+}
+
+func runQueueCLI(cmd string, args []string) int {
+	fs := flag.NewFlagSet("queue "+cmd, flag.ContinueOnError)
+	dead := fs.Bool("dead", false, "показать задачи, исчерпавшие попытки")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	args = fs.Args()
+	if len(args) < 1 {
+		cliUsage()
+		return 2
+	}
+
+	reports, messages, notifications, err := openQueues(dataDirFromEnv())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	var q *queue.Queue
+	switch args[0] {
+	case reports.Name():
+		q = reports
+	case messages.Name():
+		q = messages
+	case notifications.Name():
+		q = notifications
+	default:
+		fmt.Fprintf(os.Stderr, "неизвестная очередь %q\n", args[0])
+		return 2
+	}
+
+	switch cmd {
+	case "list":
+		jobs, err := q.Pending()
+		if *dead {
+			jobs, err = q.Dead()
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		for _, job := range jobs {
+			fmt.Println(job)
+		}
+	case "show":
+		if len(args) < 2 {
+			cliUsage()
+			return 2
+		}
+		job, isDead, err := q.Get(args[1])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Printf("id: %s\nkey: %s\ndead: %t\nattempts: %d\ncreated: %s\nnext run: %s\nlast error: %s\npayload: %s\n",
+			job.ID, job.Key, isDead, job.Attempts, job.CreatedAt.Format(time.RFC3339),
+			job.NextRunAt.Format(time.RFC3339), job.LastError, job.Payload)
+	case "requeue":
+		if len(args) < 2 {
+			cliUsage()
+			return 2
+		}
+		ids := []string{args[1]}
+		if args[1] == "all" {
+			jobs, err := q.Dead()
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+			ids = ids[:0]
+			for _, job := range jobs {
+				ids = append(ids, job.ID)
+			}
+		}
+		for _, id := range ids {
+			if err := q.Requeue(id); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", id, err)
+				return 1
+			}
+			fmt.Println("requeued", id)
+		}
+	default:
+		cliUsage()
+		return 2
+	}
+	return 0
+}
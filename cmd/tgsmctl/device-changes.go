@@ -0,0 +1,159 @@
+package main
+
+import (
+	"log/slog"
+	"path"
+	"strings"
+
+	"github.com/covrom/smart-control/internal/msgtmpl"
+	"github.com/covrom/smart-control/internal/notify"
+	"github.com/covrom/smart-control/internal/route"
+	"github.com/covrom/smart-control/internal/smartdata"
+	"github.com/covrom/smart-control/internal/store"
+)
+
+// deviceIdentity — устройство хоста для сравнения наборов дисков между отчётами
+type deviceIdentity struct {
+	Device string
+	Type   string
+	Model  string
+	Serial string
+}
+
+// key — по серийному номеру, если он известен: имена вида /dev/sdX могут
+// меняться местами после перезагрузки
+func (d deviceIdentity) key() string {
+	if d.Serial != "" {
+		return "sn:" + d.Serial
+	}
+	return "dev:" + d.Device
+}
+
+func (d deviceIdentity) String() string {
+	s := d.Device
+	if d.Model != "" {
+		s += " — " + d.Model
+	}
+	if d.Serial != "" {
+		s += ", S/N " + d.Serial
+	}
+	return s
+}
+
+// isRemovable проверяет, подпадает ли устройство под один из шаблонов съёмных
+// носителей (синтаксис path.Match, сравнение с именем, типом и моделью)
+func isRemovable(patterns []string, d deviceIdentity) bool {
+	for _, p := range patterns {
+		for _, v := range []string{d.Device, d.Type, d.Model} {
+			if v == "" {
+				continue
+			}
+			if ok, _ := path.Match(p, v); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// diffDevices возвращает пропавшие и появившиеся устройства
+func diffDevices(prev, cur []deviceIdentity) (removed, added []deviceIdentity) {
+	curKeys := make(map[string]bool, len(cur))
+	for _, d := range cur {
+		curKeys[d.key()] = true
+	}
+	prevKeys := make(map[string]bool, len(prev))
+	for _, d := range prev {
+		prevKeys[d.key()] = true
+		if !curKeys[d.key()] {
+			removed = append(removed, d)
+		}
+	}
+	for _, d := range cur {
+		if !prevKeys[d.key()] {
+			added = append(added, d)
+		}
+	}
+	return removed, added
+}
+
+// notifyDeviceChanges сообщает о дисках, пропавших или появившихся по сравнению
+// с предыдущим отчётом хоста
+func (rr *reportReceiver) notifyDeviceChanges(prevHost store.Host, report smartdata.CommonSMARTReport, publish publishFunc) error {
+	// первый отчёт хоста или список дисков не получен — сравнивать не с чем
+	if prevHost.LastReport.IsZero() || report.RawError != "" || prevHost.RawError != "" {
+		return nil
+	}
+
+	prev := make([]deviceIdentity, 0, len(prevHost.Devices))
+	prevByName := make(map[string]deviceIdentity, len(prevHost.Devices))
+	for _, name := range prevHost.Devices {
+		d := deviceIdentity{Device: name}
+		snap, ok, err := rr.st.Latest(prevHost.Hostname, name)
+		if err != nil {
+			slog.Error("failed to load latest snapshot", "host", prevHost.Hostname, "device", name, "err", err)
+		}
+		if ok {
+			d.Type = snap.Device.Type
+			d.Model = snap.Metrics.Model
+			d.Serial = snap.Metrics.Serial
+		}
+		prev = append(prev, d)
+		prevByName[name] = d
+	}
+
+	cur := make([]deviceIdentity, 0, len(report.Devices))
+	for _, dev := range report.Devices {
+		m := smartdata.ParseSmartctl(dev.SMARTData)
+		d := deviceIdentity{Device: dev.Device, Type: dev.Type, Model: m.Model, Serial: m.Serial}
+		// smartctl не смог прочитать диск, но он есть в списке — не считаем его новым
+		if d.Serial == "" {
+			if p, ok := prevByName[dev.Device]; ok {
+				d = p
+			}
+		}
+		cur = append(cur, d)
+	}
+
+	removed, added := diffDevices(prev, cur)
+
+	var lines []string
+	for _, d := range removed {
+		if isRemovable(rr.removable, d) {
+			continue
+		}
+		lines = append(lines, "➖ "+d.String())
+		if err := rr.publishDeviceChange(report, d, false, publish); err != nil {
+			return err
+		}
+	}
+	for _, d := range added {
+		if isRemovable(rr.removable, d) {
+			continue
+		}
+		lines = append(lines, "➕ "+d.String())
+		if err := rr.publishDeviceChange(report, d, true, publish); err != nil {
+			return err
+		}
+	}
+	if len(lines) > 0 {
+		slog.Info("device set changed", "host", report.Hostname, "changes", strings.Join(lines, "; "))
+	}
+	return nil
+}
+
+// publishDeviceChange публикует уведомление о появлении (added) или пропаже диска
+func (rr *reportReceiver) publishDeviceChange(report smartdata.CommonSMARTReport, d deviceIdentity, added bool, publish publishFunc) error {
+	// пропажа диска может означать отказ, поэтому это предупреждение
+	suffix, level := "removed/", store.LevelWarning
+	if added {
+		suffix, level = "added/", store.LevelOK
+	}
+	n := notify.Notification{Event: route.EventDeviceChange, Level: level}
+	if err := rr.out.render(&n, msgtmpl.DeviceChange{
+		Host: report.Hostname, OS: report.OS, Added: added, Device: d.Device, Model: d.Model, Serial: d.Serial,
+	}); err != nil {
+		return err
+	}
+	return publish(suffix+d.key(), n)
+}
@@ -6,19 +6,29 @@ import (
 	"log/slog"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"syscall"
 	"time"
 
 	"github.com/covrom/smart-control/internal/api"
+	"github.com/covrom/smart-control/internal/config"
 	"github.com/covrom/smart-control/internal/cron"
+	"github.com/covrom/smart-control/internal/i18n"
 	"github.com/covrom/smart-control/internal/llmdesc"
-	"github.com/covrom/smart-control/internal/smartdata"
+	"github.com/covrom/smart-control/internal/msgtmpl"
+	"github.com/covrom/smart-control/internal/route"
+	"github.com/covrom/smart-control/internal/store"
 	tele "gopkg.in/telebot.v3"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		os.Exit(runCLI(os.Args[1:]))
+	}
+
 	modes := strings.Split(os.Getenv("MODE"), ",") // agent,server
 	openaiBaseUrl := os.Getenv("OPENAI_BASE_URL")
 	openaiApiKey := os.Getenv("OPENAI_API_KEY")
@@ -26,12 +36,64 @@ func main() {
 	telegramToken := os.Getenv("TELEGRAM_BOT_TOKEN")
 	telegramChatID := os.Getenv("TELEGRAM_CHAT_ID")
 	token := os.Getenv("HTTP_AUTH_TOKEN")                      // agent
+	apiToken := os.Getenv("API_TOKEN")                         // server
 	hostname := os.Getenv("SMART_HOSTNAME")                    // agent
 	apiUrl := os.Getenv("COLLECTOR_URL")                       // agent
+	textfileDir := os.Getenv("TEXTFILE_DIR")                   // agent
 	cronSched := strings.Trim(os.Getenv("CRON_SCHEDULE"), `"`) // agent
 	if cronSched == "" {
 		cronSched = "55 23 * * *"
 	}
+	dataDir := dataDirFromEnv() // server
+	if apiToken == "" {
+		apiToken = token
+	}
+	missingGrace := time.Hour // server
+	if v := os.Getenv("MISSING_GRACE"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			log.Fatalf("invalid MISSING_GRACE: %v", err)
+		}
+		missingGrace = d
+	}
+	var digestSched *cron.CronSchedule // server
+	if v := strings.Trim(os.Getenv("DIGEST_SCHEDULE"), `"`); v != "" {
+		sc, err := cron.NewCronScheduleFromString(v)
+		if err != nil {
+			log.Fatalf("invalid DIGEST_SCHEDULE: %v", err)
+		}
+		digestSched = sc
+	}
+	alertRemind := 24 * time.Hour // server
+	if v := os.Getenv("ALERT_REMIND"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			log.Fatalf("invalid ALERT_REMIND: %v", err)
+		}
+		alertRemind = d
+	}
+	reuseMaxAge := 7 * 24 * time.Hour // server
+	if v := os.Getenv("LLM_REUSE_MAX_AGE"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			log.Fatalf("invalid LLM_REUSE_MAX_AGE: %v", err)
+		}
+		reuseMaxAge = d
+	}
+	llmInput := os.Getenv("LLM_INPUT") // server
+	switch llmInput {
+	case "", llmdesc.InputCompact, llmdesc.InputRaw:
+	default:
+		log.Fatalf("invalid LLM_INPUT: must be %s or %s, got %q", llmdesc.InputCompact, llmdesc.InputRaw, llmInput)
+	}
+	lang := i18n.Default // server
+	if v := os.Getenv("LANGUAGE"); v != "" {
+		l, err := i18n.Parse(v)
+		if err != nil {
+			log.Fatalf("invalid LANGUAGE: %v", err)
+		}
+		lang = l
+	}
 
 	var isAgent, isServer bool
 	for _, mode := range modes {
@@ -61,16 +123,50 @@ func main() {
 			return
 		}
 
-		slog.Info("cron sheduling", "cronSched", cronSched)
+		slog.Info("cron sheduling", "cronSched", cronSched, "textfileDir", textfileDir)
 
 		wg.Add(1)
-		go workerSendReports(ctx, wg, apiUrl, token, hostname, sched)
+		go workerSendReports(ctx, wg, apiUrl, token, hostname, textfileDir, cronSched, splitList(os.Getenv("SMART_LABELS")), sched)
 	}
 
 	// сервер
 	if isServer {
-		chReps := make(chan smartdata.CommonSMARTReport, 100)
-		chTgMsg := make(chan string, 100)
+		configFile := os.Getenv("CONFIG_FILE")
+		if configFile == "" {
+			configFile = filepath.Join(dataDir, "config.json")
+		}
+		cfg, err := config.Load(configFile)
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+
+		// шаблоны проверяются при запуске, чтобы ошибка в них не проявилась
+		// только на первом уведомлении
+		tmpl, err := msgtmpl.Load(os.Getenv("TEMPLATES_DIR"), lang)
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+
+		reports, messages, notifications, err := openQueues(dataDir)
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+
+		defaultChats, err := parseChatIDs(telegramChatID)
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+		tg := &telegramNotifier{messages: messages, routes: cfg.Routes, lang: lang}
+		for _, id := range defaultChats {
+			tg.defaults = append(tg.defaults, route.Destination{ChatID: id})
+		}
+		out := &outbox{telegram: tg, notifications: notifications, routes: cfg.Routes, tmpl: tmpl}
+		messages.OnDead(tg.deadLetter)
+		notifications.OnDead(out.deadNotification)
 
 		pref := tele.Settings{
 			Token:  telegramToken,
@@ -83,10 +179,32 @@ func main() {
 			return
 		}
 
-		srv := api.NewHttpServer(token, chReps)
+		st, err := store.Open(dataDir)
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+
+		// без списка поставщиков в файле настроек — один из OPENAI_*
+		providers := cfg.LLM
+		if len(providers) == 0 {
+			providers = []llmdesc.ProviderConfig{{
+				Name:    "openai",
+				BaseURL: openaiBaseUrl,
+				APIKey:  openaiApiKey,
+				Model:   openaiModel,
+				Input:   llmInput,
+			}}
+		}
+		llmDescriber := llmdesc.NewLLMDescriber(providers, lang, reuseMaxAge)
+
+		srv := api.NewHttpServer(token, apiToken, reports, messages, notifications, st, llmDescriber, lang)
+
+		wg.Add(1)
+		go api.TgSendWorker(ctx, b, telegramChatID, messages, wg)
 
 		wg.Add(1)
-		go api.TgSendWorker(ctx, b, telegramChatID, chTgMsg, wg)
+		go workerNotify(ctx, wg, cfg.Notifiers(), notifications)
 
 		wg.Add(1)
 		go func() {
@@ -95,10 +213,56 @@ func main() {
 			srv.Shutdown(context.Background())
 		}()
 
-		llmDescriber := llmdesc.NewLLMDescriber(openaiBaseUrl, openaiApiKey, openaiModel)
+		wg.Add(1)
+		go workerWatchdog(ctx, wg, st, out, missingGrace)
+
+		if digestSched != nil {
+			wg.Add(1)
+			go workerDigest(ctx, wg, st, out, digestSched)
+		}
+
+		// команды и кнопки доступны во всех чатах, куда приходят уведомления
+		allowedChats, err := parseChatIDs(splitList(os.Getenv("TELEGRAM_ALLOWED_CHATS"))...)
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+		for _, id := range append(defaultChats, route.Chats(cfg.Routes)...) {
+			if !slices.Contains(allowedChats, id) {
+				allowedChats = append(allowedChats, id)
+			}
+		}
+		rr := &reportReceiver{
+			hostname:     hostname,
+			st:           st,
+			out:          out,
+			llmDescriber: llmDescriber,
+			removable:    splitList(os.Getenv("REMOVABLE_DEVICES")),
+			remind:       alertRemind,
+		}
+
+		bc := &botCommands{
+			ctx:          ctx,
+			st:           st,
+			llmDescriber: llmDescriber,
+			out:          out,
+			reports:      rr,
+			allowed:      allowedChats,
+		}
+		bc.register(b)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.Start()
+		}()
+		go func() {
+			<-ctx.Done()
+			b.Stop()
+		}()
 
 		wg.Add(1)
-		go workerRecvReports(ctx, wg, hostname, chTgMsg, chReps, llmDescriber)
+		go workerRecvReports(ctx, wg, rr, reports)
 	}
 
 	<-ctx.Done()
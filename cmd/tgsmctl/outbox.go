@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/covrom/smart-control/internal/api"
+	"github.com/covrom/smart-control/internal/i18n"
+	"github.com/covrom/smart-control/internal/msgtmpl"
+	"github.com/covrom/smart-control/internal/notify"
+	"github.com/covrom/smart-control/internal/queue"
+	"github.com/covrom/smart-control/internal/route"
+	"github.com/covrom/smart-control/internal/tghtml"
+)
+
+// outbox публикует уведомления: в чаты Telegram и в дополнительные каналы,
+// выбранные правилами маршрутизации. Доставка идёт через дисковые очереди,
+// поэтому публикация только ставит задачи.
+type outbox struct {
+	telegram      *telegramNotifier
+	notifications *queue.Queue // задачи доставки в дополнительные каналы
+	routes        []route.Route
+	tmpl          *msgtmpl.Templates
+}
+
+// render оформляет текст уведомления о событии n.Event по данным data на
+// языке сервера и переводы на остальные языки из правил маршрутизации
+func (o *outbox) render(n *notify.Notification, data any) error {
+	html, err := o.tmpl.Render(o.tmpl.Lang(), n.Event, data)
+	if err != nil {
+		return fmt.Errorf("render: %w", err)
+	}
+	n.HTML = html
+	for _, r := range o.routes {
+		if r.Language == "" || r.Language == o.tmpl.Lang() {
+			continue
+		}
+		if _, ok := n.Translations[r.Language]; ok {
+			continue
+		}
+		html, err := o.tmpl.Render(r.Language, n.Event, data)
+		if err != nil {
+			return fmt.Errorf("render %s: %w", r.Language, err)
+		}
+		if n.Translations == nil {
+			n.Translations = make(map[i18n.Lang]string)
+		}
+		n.Translations[r.Language] = html
+	}
+	return nil
+}
+
+// publish ставит уведомление в очереди всех его получателей. Ключи задач
+// производны от n.Key, что сохраняет идемпотентность.
+func (o *outbox) publish(n notify.Notification) error {
+	if err := o.telegram.Notify(context.Background(), n); err != nil {
+		return err
+	}
+	for _, ch := range route.Notifiers(o.routes, n.Subject()) {
+		if _, err := o.notifications.Enqueue(n.Key+">"+ch.Name, notify.Job{Notifier: ch.Name, Notification: n.In(ch.Lang)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deadNotification сообщает в Telegram, что уведомление не удалось доставить
+// в дополнительный канал
+func (o *outbox) deadNotification(job queue.Job) {
+	var j notify.Job
+	job.Decode(&j)
+	l := o.telegram.lang
+	o.telegram.deadNotice(job, tghtml.Sprintf(l.T("dead.channel"), j.Notifier), j.Notification.Text(), route.Destination{})
+}
+
+// telegramNotifier — канал Telegram: ставит сообщение в очередь отправки
+// для каждого чата, выбранного правилами маршрутизации
+type telegramNotifier struct {
+	messages *queue.Queue
+	routes   []route.Route
+	defaults []route.Destination // получатели, если ни одно правило не подошло
+	lang     i18n.Lang           // язык сервера
+}
+
+// Notify ставит уведомление в очередь сообщений Telegram. Из вложений
+// отправляется только изображение; вывод smartctl доступен кнопкой.
+func (t *telegramNotifier) Notify(ctx context.Context, n notify.Notification) error {
+	dests := route.Resolve(t.routes, t.defaults, n.Subject())
+	if len(dests) == 0 {
+		slog.Warn("no recipients for message", "key", n.Key, "event", n.Event, "host", n.Host)
+		return nil
+	}
+	msg := api.Message{HTML: true}
+	if n.Buttons {
+		msg.Host, msg.Device, msg.Muted = n.Host, n.Device, n.Muted
+	}
+	if img, ok := n.Image(); ok {
+		msg.Photo = img.Data
+	}
+	for _, d := range dests {
+		m := msg
+		m.ChatID, m.ThreadID = d.ChatID, d.ThreadID
+		m.Lang = d.Lang.Or(t.lang)
+		m.Text = n.In(m.Lang).HTML
+		if _, err := t.messages.Enqueue(fmt.Sprintf("%s>%d:%d", n.Key, d.ChatID, d.ThreadID), m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deadLetter сообщает в чаты по умолчанию о сообщении, которое не удалось
+// доставить, чтобы критичное оповещение не потерялось молча. Само сообщение
+// остаётся в dead и доступно в API и команде queue list -dead.
+func (t *telegramNotifier) deadLetter(job queue.Job) {
+	if strings.HasPrefix(job.Key, "dead/") {
+		// не удалось доставить и само извещение: остаётся только журнал
+		return
+	}
+	var msg api.Message
+	job.Decode(&msg)
+	text := msg.Text
+	if msg.HTML {
+		text = tghtml.Plain(text)
+	}
+	t.deadNotice(job, tghtml.Sprintf(t.lang.T("dead.chat"), msg.ChatID), text,
+		route.Destination{ChatID: msg.ChatID, ThreadID: msg.ThreadID})
+}
+
+// deadNotice ставит извещение о недоставленном уведомлении в чаты по
+// умолчанию, кроме skip — чата, в который доставить не удалось
+func (t *telegramNotifier) deadNotice(job queue.Job, where, text string, skip route.Destination) {
+	if r := []rune(text); len(r) > 500 {
+		text = string(r[:500]) + "…"
+	}
+	notice := api.Message{HTML: true, Lang: t.lang, Text: tghtml.Sprintf(t.lang.T("dead.notice"),
+		tghtml.HTML(where), job.Attempts, job.LastError, job.ID, tghtml.HTML(tghtml.Expandable(tghtml.Escape(text))))}
+	for _, d := range t.defaults {
+		if d.Same(skip) {
+			continue
+		}
+		m := notice
+		m.ChatID, m.ThreadID = d.ChatID, d.ThreadID
+		if _, err := t.messages.Enqueue(fmt.Sprintf("dead/%s>%d:%d", job.ID, d.ChatID, d.ThreadID), m); err != nil {
+			slog.Error("enqueue dead letter notice", "id", job.ID, "err", err)
+		}
+	}
+}
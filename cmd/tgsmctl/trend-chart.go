@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/covrom/smart-control/internal/chart"
+	"github.com/covrom/smart-control/internal/smartdata"
+	"github.com/covrom/smart-control/internal/store"
+)
+
+// chartMetrics — показатели на графиках трендов: нагрев, переназначенные
+// секторы и износ лучше всего видны на кривой
+var chartMetrics = []string{
+	smartdata.MetricTemperature,
+	smartdata.MetricReallocated,
+	smartdata.MetricPercentageUsed,
+	smartdata.MetricBytesWritten,
+}
+
+// alertChartPeriod — период графика в предупреждениях
+const alertChartPeriod = 30 * 24 * time.Hour
+
+// trendChart рисует PNG с графиками показателей устройства за период.
+// Показатели, для которых меньше двух значений, пропускаются; если рисовать
+// нечего, возвращается nil.
+func trendChart(st *store.Store, host, device string, from, to time.Time) ([]byte, error) {
+	history, err := st.History(host, device, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("load history: %w", err)
+	}
+	series := store.Series(history, chartMetrics...)
+	var panels []chart.Panel
+	for _, m := range chartMetrics {
+		if len(series[m]) < 2 {
+			continue
+		}
+		p := chart.Panel{Title: m}
+		for _, pt := range series[m] {
+			p.Points = append(p.Points, chart.Point{Time: pt.Time, Value: pt.Value})
+		}
+		panels = append(panels, p)
+	}
+	if to.IsZero() {
+		to = time.Now()
+	}
+	return chart.Render(host+" "+device, from, to, panels)
+}
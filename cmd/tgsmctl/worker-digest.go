@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/covrom/smart-control/internal/cron"
+	"github.com/covrom/smart-control/internal/msgtmpl"
+	"github.com/covrom/smart-control/internal/notify"
+	"github.com/covrom/smart-control/internal/route"
+	"github.com/covrom/smart-control/internal/smartdata"
+	"github.com/covrom/smart-control/internal/store"
+)
+
+// digestTopConcerns — сколько проблемных устройств перечислять в сводке
+const digestTopConcerns = 10
+
+// digestDeltaMetrics — показатели, рост которых за период попадает в сводку
+var digestDeltaMetrics = []string{
+	smartdata.MetricReallocated,
+	smartdata.MetricPending,
+	smartdata.MetricOfflineUncorr,
+	smartdata.MetricGrownDefects,
+	smartdata.MetricMediaErrors,
+	smartdata.MetricErrorLogEntries,
+	smartdata.MetricCRCErrors,
+	smartdata.MetricPercentageUsed,
+}
+
+// workerDigest по расписанию сервера отправляет сводку по всем хостам и дискам
+func workerDigest(ctx context.Context, wg *sync.WaitGroup, st *store.Store, out *outbox, schedule *cron.CronSchedule) {
+	defer wg.Done()
+
+	next := schedule.NextRun(time.Now())
+	slog.Info("workerDigest started", "next", next)
+
+	for {
+		timer := time.NewTimer(time.Until(next))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			// период сводки — интервал между соседними запусками расписания
+			following := schedule.NextRun(next)
+			from := next.Add(-following.Sub(next))
+
+			n := notify.Notification{
+				Key:   "digest/" + next.UTC().Format(time.RFC3339),
+				Event: route.EventDigest,
+				Level: store.LevelOK,
+			}
+			data, err := digestData(st, from, next)
+			if err == nil {
+				err = out.render(&n, data)
+			}
+			if err != nil {
+				slog.Error("digest: build", "err", err)
+			} else if err := out.publish(n); err != nil {
+				slog.Error("digest: enqueue message", "err", err)
+			}
+			next = following
+			slog.Info("next digest", "at", next)
+		}
+	}
+}
+
+// digestData собирает данные сводки за период [from, to]
+func digestData(st *store.Store, from, to time.Time) (msgtmpl.Digest, error) {
+	hosts, err := st.Hosts()
+	if err != nil {
+		return msgtmpl.Digest{}, fmt.Errorf("load hosts: %w", err)
+	}
+
+	data := msgtmpl.Digest{From: from, To: to, Hosts: len(hosts)}
+	counts := map[store.Level]int{}
+	for _, h := range hosts {
+		if !h.MissingSince.IsZero() {
+			data.Missing = append(data.Missing, msgtmpl.DigestHost{Host: h.Hostname, LastReport: h.LastReport})
+		}
+		if h.RawError != "" {
+			data.Failed = append(data.Failed, h.Hostname)
+		}
+		devices, err := st.DeviceSummaries(h)
+		if err != nil {
+			return msgtmpl.Digest{}, fmt.Errorf("load devices of %s: %w", h.Hostname, err)
+		}
+		for _, sum := range devices {
+			data.Devices++
+			d := msgtmpl.DigestDevice{Host: h.Hostname, Device: sum.Device, Model: sum.Model}
+			d.Level, d.Since = h.DeviceLevel(sum)
+			counts[d.Level]++
+			if d.Level.Rank() >= store.LevelWarning.Rank() {
+				data.Concerns = append(data.Concerns, d)
+			}
+
+			base, ok, err := st.Previous(h.Hostname, sum.Device, from)
+			if err != nil {
+				return msgtmpl.Digest{}, fmt.Errorf("load snapshot of %s %s: %w", h.Hostname, sum.Device, err)
+			}
+			if !ok {
+				continue
+			}
+			var changes []string
+			for _, m := range digestDeltaMetrics {
+				cur, ok1 := sum.Values[m]
+				old, ok2 := base.Metrics.Values[m]
+				if ok1 && ok2 && cur > old {
+					changes = append(changes, fmt.Sprintf("%s +%g", m, cur-old))
+				}
+			}
+			if len(changes) > 0 {
+				data.Deltas = append(data.Deltas, msgtmpl.DigestDelta{Host: h.Hostname, Device: sum.Device, Model: sum.Model, Changes: changes})
+			}
+		}
+	}
+	data.OK, data.Warning = counts[store.LevelOK], counts[store.LevelWarning]
+	data.Critical, data.Unknown = counts[store.LevelCritical], counts[store.LevelUnknown]
+
+	sort.SliceStable(data.Concerns, func(i, j int) bool { return data.Concerns[i].Level.Rank() > data.Concerns[j].Level.Rank() })
+	if len(data.Concerns) > digestTopConcerns {
+		data.MoreConcerns = len(data.Concerns) - digestTopConcerns
+		data.Concerns = data.Concerns[:digestTopConcerns]
+	}
+	return data, nil
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/covrom/smart-control/internal/notify"
+	"github.com/covrom/smart-control/internal/queue"
+)
+
+// workerNotify доставляет уведомления в дополнительные каналы (почта и др.)
+func workerNotify(ctx context.Context, wg *sync.WaitGroup, notifiers map[string]notify.Notifier, notifications *queue.Queue) {
+	slog.Info("workerNotify started", "notifiers", len(notifiers))
+	notifications.Run(ctx, wg, notify.Handler(notifiers))
+}
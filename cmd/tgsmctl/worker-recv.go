@@ -2,77 +2,281 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"html/template"
 	"log/slog"
-	"os"
-	"path/filepath"
-	"strings"
+	"slices"
 	"sync"
+	"time"
 
+	"github.com/covrom/smart-control/internal/alert"
 	"github.com/covrom/smart-control/internal/llmdesc"
+	"github.com/covrom/smart-control/internal/msgtmpl"
+	"github.com/covrom/smart-control/internal/notify"
+	"github.com/covrom/smart-control/internal/queue"
+	"github.com/covrom/smart-control/internal/route"
 	"github.com/covrom/smart-control/internal/smartdata"
+	"github.com/covrom/smart-control/internal/store"
+	"github.com/covrom/smart-control/internal/tghtml"
 )
 
-func workerRecvReports(ctx context.Context, wg *sync.WaitGroup, hostname string, chTgMsg chan<- string, chReps <-chan smartdata.CommonSMARTReport, llmDescriber *llmdesc.LLMSmartDescriber) {
-	defer wg.Done()
+// reportReceiver анализирует отчёты из очереди и ставит уведомления в очередь сообщений
+type reportReceiver struct {
+	hostname     string
+	st           *store.Store
+	out          *outbox
+	llmDescriber *llmdesc.LLMSmartDescriber
+	removable    []string      // шаблоны съёмных устройств, см. isRemovable
+	remind       time.Duration // интервал напоминаний о нерешённых проблемах, 0 — без напоминаний
+}
 
-	slog.Info("workerRecvReports started")
+// publishFunc публикует уведомление об обрабатываемом отчёте, дополняя его
+// ключом и сведениями о хосте; suffix отличает уведомления одного отчёта
+// друг от друга
+type publishFunc func(suffix string, n notify.Notification) error
 
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case report := <-chReps:
-			slog.Info("receive report", "hostname", report.Hostname)
-			if report.RawError != "" {
-				chTgMsg <- fmt.Sprintf("❌ Ошибка для %s (%s)\n%s",
-					report.Hostname, report.OS, report.RawError)
-			} else {
-				for _, d := range report.Devices {
-					if d.RawError != "" {
-						chTgMsg <- fmt.Sprintf("❌ Ошибка для %s (%s)\nУстройство: %s\n%s",
-							report.Hostname, report.OS, d.Device, d.RawError)
-					} else {
-						var prev smartdata.SMARTDevice
-						// сохраняем анализ (переменную d) в файл json с именем, соответствующим report.Hostname и d.Device (с заменой небезопасных символов на знак '_')
-						// предварительно загружаем из этого файла предыдущую версию (если файл существует) в переменную prev
-						filename := fmt.Sprintf("%s_%s.json", report.Hostname, d.Device)
-						// заменяем небезопасные символы на '_'
-						for _, r := range filename {
-							if r == '/' || r == '\\' || r == ':' || r == '*' || r == '?' || r == '"' || r == '<' || r == '>' || r == '|' {
-								filename = strings.ReplaceAll(filename, string(r), "_")
-							}
-						}
-
-						filename = filepath.Join("/var/lib/smart_reports_data/", filename)
-
-						// загружаем предыдущую версию, если файл существует
-						if data, err := os.ReadFile(filename); err == nil {
-							if err := json.Unmarshal(data, &prev); err != nil {
-								slog.Error("failed to unmarshal prev data", "err", err)
-							}
-						}
-						// сохраняем текущую версию в файл
-						data, err := json.Marshal(d)
-						if err != nil {
-							slog.Error("failed to marshal current data", "err", err)
-						} else {
-							if err := os.WriteFile(filename, data, 0644); err != nil {
-								slog.Error("failed to write file", "err", err)
-							}
-						}
-
-						msg := fmt.Sprintf("💻 Анализ для %s (%s)\n📀 Устройство: %s, точки монтирования:\n%s\n\n%s",
-							report.Hostname, report.OS, d.Device, strings.Join(d.MountPaths, "\n"), llmDescriber.Describe(ctx, hostname, d, prev))
-						if len(d.MountPaths) == 0 {
-							msg = fmt.Sprintf("💻 Анализ для %s (%s)\n📀 Устройство: %s, точки монтирования отсутствуют\n\n%s",
-								report.Hostname, report.OS, d.Device, llmDescriber.Describe(ctx, hostname, d, prev))
-						}
-						chTgMsg <- msg
-					}
+// saveHost обновляет состояние хоста, если отчёт не старее уже сохранённого
+func saveHost(st *store.Store, report smartdata.CommonSMARTReport, receivedAt time.Time) error {
+	_, err := st.UpdateHost(report.Hostname, func(h *store.Host) bool {
+		if report.Timestamp.Before(h.LastReport) {
+			return false
+		}
+
+		h.Hostname = report.Hostname
+		h.OS = report.OS
+		h.AgentVersion = report.AgentVersion
+		h.CronSchedule = report.CronSchedule
+		h.Labels = report.Labels
+		h.LastReport = report.Timestamp
+		h.ReceivedAt = receivedAt
+		h.RawError, h.ErrorCode = report.RawError, report.ErrorCode
+		h.MissingSince = time.Time{}
+		// при ошибке получения списка дисков оставляем прежний список
+		if report.RawError == "" {
+			h.Devices = make([]string, 0, len(report.Devices))
+			for _, d := range report.Devices {
+				h.Devices = append(h.Devices, d.Device)
+			}
+			// состояние оповещений пропавших устройств больше не нужно
+			for dev := range h.Alerts {
+				if !slices.Contains(h.Devices, dev) {
+					delete(h.Alerts, dev)
 				}
 			}
 		}
+		return true
+	})
+	return err
+}
+
+func workerRecvReports(ctx context.Context, wg *sync.WaitGroup, rr *reportReceiver, reports *queue.Queue) {
+	slog.Info("workerRecvReports started")
+	reports.Run(ctx, wg, rr.handle)
+}
+
+func (rr *reportReceiver) handle(ctx context.Context, job queue.Job) error {
+	var report smartdata.CommonSMARTReport
+	if err := job.Decode(&report); err != nil {
+		return queue.Permanent(err)
+	}
+
+	slog.Info("receive report", "hostname", report.Hostname)
+
+	// ключи уведомлений производны от ключа отчёта, поэтому при повторной
+	// обработке отчёта после сбоя уже поставленные сообщения не дублируются
+	publish := rr.publisher(job.Key, report)
+
+	// уведомления, зависящие от прежнего состояния хоста, ставим до его
+	// сохранения, чтобы при сбое между этими шагами они не потерялись
+	prevHost, _, err := rr.st.Host(report.Hostname)
+	if err != nil {
+		return fmt.Errorf("load host: %w", err)
+	}
+	if !report.Timestamp.Before(prevHost.LastReport) {
+		// хост, пропустивший отчёт, вернулся
+		if !prevHost.MissingSince.IsZero() {
+			n := notify.Notification{Event: route.EventMissingHost, Level: store.LevelOK}
+			if err := rr.out.render(&n, msgtmpl.MissingHost{
+				Host: report.Hostname, OS: report.OS, Recovered: true, LastReport: prevHost.LastReport,
+			}); err != nil {
+				return err
+			}
+			if err := publish("recovered", n); err != nil {
+				return err
+			}
+		}
+		if err := rr.notifyDeviceChanges(prevHost, report, publish); err != nil {
+			return err
+		}
+	}
+
+	if err := saveHost(rr.st, report, job.CreatedAt); err != nil {
+		return fmt.Errorf("save host: %w", err)
+	}
+
+	if report.RawError != "" {
+		n := notify.Notification{Event: route.EventReportError, Level: store.LevelWarning}
+		if err := rr.out.render(&n, msgtmpl.ReportError{Host: report.Hostname, OS: report.OS, Code: report.ErrorCode, Error: report.RawError}); err != nil {
+			return err
+		}
+		return publish("error", n)
+	}
+
+	for _, d := range report.Devices {
+		if d.RawError != "" {
+			n := notify.Notification{Event: route.EventDeviceError, Device: d.Device, Level: store.LevelWarning}
+			if err := rr.out.render(&n, msgtmpl.DeviceError{Host: report.Hostname, OS: report.OS, Device: d.Device, Code: d.ErrorCode, Error: d.RawError}); err != nil {
+				return err
+			}
+			if err := publish(d.Device, n); err != nil {
+				return err
+			}
+			continue
+		}
+
+		prev, _, err := rr.st.Previous(report.Hostname, d.Device, report.Timestamp)
+		if err != nil {
+			slog.Error("failed to load prev snapshot", "err", err)
+		}
+
+		// снимок этого отчёта уже мог быть сохранён до сбоя: повторно LLM не вызываем
+		snap, ok, err := rr.st.Snapshot(report.Hostname, d.Device, report.Timestamp)
+		if err != nil || !ok || snap.Analysis == nil {
+			snap = store.Snapshot{
+				Hostname:  report.Hostname,
+				Timestamp: report.Timestamp,
+				Device:    d,
+				Metrics:   smartdata.ParseSmartctl(d.SMARTData),
+			}
+			snap.Analysis = rr.llmDescriber.Analyze(ctx, rr.hostname, snap, prev)
+			if ctx.Err() != nil {
+				// остановка сервера, а не сбой LLM: отчёт обработается после запуска
+				return ctx.Err()
+			}
+			if err := rr.st.SaveSnapshot(snap); err != nil {
+				return fmt.Errorf("save snapshot: %w", err)
+			}
+		}
+
+		if err := rr.alertDevice(report, d, snap, publish); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// publisher возвращает publishFunc, которая дополняет уведомления об отчёте
+// report ключом key и сведениями о хосте
+func (rr *reportReceiver) publisher(key string, report smartdata.CommonSMARTReport) publishFunc {
+	return func(suffix string, n notify.Notification) error {
+		n.Key = key + "/" + suffix
+		n.Host, n.Labels = report.Hostname, report.Labels
+		return rr.out.publish(n)
+	}
+}
+
+// alertDevice уведомляет о смене состояния устройства по машине состояний
+// alert.Next. Устройства без изменений не порождают сообщений.
+func (rr *reportReceiver) alertDevice(report smartdata.CommonSMARTReport, d smartdata.SMARTDevice, snap store.Snapshot, publish publishFunc) error {
+	h, _, err := rr.st.Host(report.Hostname)
+	if err != nil {
+		return fmt.Errorf("load host: %w", err)
+	}
+	prev, _ := h.Alert(d.Device)
+	// отчёт пришёл не по порядку. Тот же отчёт учитывается снова после сбоя
+	// и после /analyze: при прежнем заключении событие не возникает, а при
+	// новом — сообщается смена уровня.
+	if report.Timestamp.Before(prev.ReportAt) {
+		return nil
+	}
+
+	// без заключения машина состояний молчит, поэтому о недоступности LLM
+	// сообщаем отдельно, с показателями диска, — один раз до первого
+	// полученного заключения
+	failed := snap.Analysis == nil
+	if failed && !prev.AnalysisFailed && !prev.Muted {
+		n := notify.Notification{Event: route.EventDeviceError, Device: d.Device, Level: store.LevelWarning}
+		if err := rr.out.render(&n, msgtmpl.DeviceError{
+			Host: report.Hostname, OS: report.OS, Device: d.Device, Code: smartdata.ErrCodeAnalysis, Metrics: snap.Metrics,
+		}); err != nil {
+			return err
+		}
+		if err := publish(d.Device+"/analysis", n); err != nil {
+			return err
+		}
+	}
+
+	level := snap.Analysis.Verdict()
+	next, ev := alert.Next(prev, level, report.Timestamp, rr.remind)
+
+	if ev != alert.EventNone {
+		n := notify.Notification{
+			Event:  route.EventAnalysis,
+			Device: d.Device,
+			Level:  level,
+			// о проблемах — с кнопками подтверждения, откладывания и отключения
+			Buttons: ev != alert.EventRecovered,
+			Muted:   next.Muted,
+		}
+		if err := rr.out.render(&n, analysisData(report, d, snap, ev, prev)); err != nil {
+			return err
+		}
+		// к предупреждениям прикладываем график: рост счётчиков нагляднее чисел,
+		// и вывод smartctl для каналов без кнопки «Сырые данные»
+		if ev != alert.EventRecovered && level.Rank() >= store.LevelWarning.Rank() {
+			name := report.Hostname + "_" + store.DeviceID(d.Device)
+			png, err := trendChart(rr.st, report.Hostname, d.Device, report.Timestamp.Add(-alertChartPeriod), report.Timestamp)
+			if err != nil {
+				slog.Error("failed to render trend chart", "host", report.Hostname, "device", d.Device, "err", err)
+			}
+			if png != nil {
+				n.Attachments = append(n.Attachments, notify.Attachment{Name: name + "_trend.png", ContentType: "image/png", Data: png})
+			}
+			if d.SMARTData != "" {
+				n.Attachments = append(n.Attachments, notify.Attachment{Name: name + "_smartctl.txt", ContentType: "text/plain; charset=utf-8", Data: []byte(d.SMARTData)})
+			}
+		}
+		if err := publish(d.Device, n); err != nil {
+			return err
+		}
+	}
+
+	// состояние сохраняем после постановки сообщения: при повторной обработке
+	// отчёта событие вычислится заново, а ключ сообщения не даст дубля.
+	// Подтверждение, откладывание и отключение могли измениться кнопками
+	// за время обработки, поэтому их не перезаписываем.
+	_, err = rr.st.UpdateAlert(report.Hostname, d.Device, func(a *store.AlertState) {
+		if next.Level != prev.Level {
+			a.AckedBy, a.AckedAt = next.AckedBy, next.AckedAt
+		}
+		a.Level, a.Since, a.NotifiedAt, a.ReportAt = next.Level, next.Since, next.NotifiedAt, next.ReportAt
+		a.AnalysisFailed = failed
+	})
+	if err != nil {
+		return fmt.Errorf("save alert state: %w", err)
+	}
+	if ev != alert.EventNone {
+		slog.Info("device alert", "host", report.Hostname, "device", d.Device, "event", ev, "level", level)
+	}
+	return nil
+}
+
+// analysisData — данные шаблона уведомления об анализе устройства: событие
+// оповещения, заключение LLM и показатели для таблицы атрибутов SMART
+func analysisData(report smartdata.CommonSMARTReport, d smartdata.SMARTDevice, snap store.Snapshot, ev alert.Event, prev store.AlertState) msgtmpl.Analysis {
+	data := msgtmpl.Analysis{
+		Alert:      ev,
+		Level:      snap.Analysis.Verdict(),
+		PrevLevel:  prev.Level,
+		Since:      prev.Since,
+		Host:       report.Hostname,
+		OS:         report.OS,
+		Device:     d.Device,
+		MountPaths: d.MountPaths,
+		Metrics:    snap.Metrics,
+	}
+	if snap.Analysis != nil {
+		data.Text = template.HTML(tghtml.Markdown(snap.Analysis.Text))
 	}
+	return data
 }
@@ -9,9 +9,12 @@ import (
 	"github.com/covrom/smart-control/internal/api"
 	"github.com/covrom/smart-control/internal/cron"
 	"github.com/covrom/smart-control/internal/disk"
+	"github.com/covrom/smart-control/internal/metrics"
 )
 
-func workerSendReports(ctx context.Context, wg *sync.WaitGroup, apiUrl, token, hostname string, schedule *cron.CronSchedule) {
+// workerSendReports по расписанию собирает отчёт и отправляет его на сервер,
+// либо, если задан textfileDir, сохраняет метрики для node_exporter
+func workerSendReports(ctx context.Context, wg *sync.WaitGroup, apiUrl, token, hostname, textfileDir, cronSched string, labels []string, schedule *cron.CronSchedule) {
 	defer wg.Done()
 
 	today := time.Now().Add(5 * time.Second)
@@ -25,7 +28,15 @@ func workerSendReports(ctx context.Context, wg *sync.WaitGroup, apiUrl, token, h
 			return
 		case <-timer.C:
 			report := disk.SmartReportOnAllDevices(ctx, hostname)
-			if err := api.SendReport(ctx, apiUrl, token, report); err != nil {
+			report.CronSchedule = cronSched
+			report.Labels = labels
+			if textfileDir != "" {
+				if err := metrics.WriteTextfile(textfileDir, report); err != nil {
+					slog.Error("textfile writing error", "err", err)
+				} else {
+					slog.Info("textfile written", "dir", textfileDir)
+				}
+			} else if err := api.SendReport(ctx, apiUrl, token, report); err != nil {
 				slog.Error("report sending error", "err", err)
 			}
 			today = schedule.NextRun(today)
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/covrom/smart-control/internal/cron"
+	"github.com/covrom/smart-control/internal/msgtmpl"
+	"github.com/covrom/smart-control/internal/notify"
+	"github.com/covrom/smart-control/internal/route"
+	"github.com/covrom/smart-control/internal/store"
+)
+
+// watchdogInterval — период проверки пропущенных отчётов
+const watchdogInterval = time.Minute
+
+// expectedReport возвращает время, к которому ожидается следующий отчёт хоста
+// по его расписанию. false — расписание хоста неизвестно.
+func expectedReport(h store.Host) (time.Time, bool) {
+	if h.CronSchedule == "" || h.LastReport.IsZero() {
+		return time.Time{}, false
+	}
+	sched, err := cron.NewCronScheduleFromString(h.CronSchedule)
+	if err != nil {
+		slog.Error("invalid host cron schedule", "host", h.Hostname, "cron", h.CronSchedule, "err", err)
+		return time.Time{}, false
+	}
+	// LastReport сохраняет часовой пояс агента, поэтому расписание считается в его времени
+	return sched.NextRun(h.LastReport), true
+}
+
+// workerWatchdog отправляет уведомление, если хост не прислал отчёт к ожидаемому
+// по расписанию времени плюс grace. Сообщение о возвращении хоста отправляет
+// workerRecvReports при получении следующего отчёта.
+func workerWatchdog(ctx context.Context, wg *sync.WaitGroup, st *store.Store, out *outbox, grace time.Duration) {
+	defer wg.Done()
+
+	slog.Info("workerWatchdog started", "grace", grace)
+
+	ticker := time.NewTicker(watchdogInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			checkMissingHosts(st, out, grace, now)
+		}
+	}
+}
+
+func checkMissingHosts(st *store.Store, out *outbox, grace time.Duration, now time.Time) {
+	hosts, err := st.Hosts()
+	if err != nil {
+		slog.Error("watchdog: load hosts", "err", err)
+		return
+	}
+	for _, h := range hosts {
+		if !h.MissingSince.IsZero() {
+			continue
+		}
+		expected, ok := expectedReport(h)
+		if !ok || now.Before(expected.Add(grace)) {
+			continue
+		}
+
+		// ставим уведомление до отметки хоста: ключ не даст его продублировать
+		key := fmt.Sprintf("missing/%s@%s", h.Hostname, h.LastReport.UTC().Format(time.RFC3339Nano))
+		n := notify.Notification{Key: key, Event: route.EventMissingHost, Host: h.Hostname, Labels: h.Labels, Level: store.LevelWarning}
+		if err := out.render(&n, msgtmpl.MissingHost{
+			Host: h.Hostname, OS: h.OS, Expected: expected, ReceivedAt: h.ReceivedAt, LastReport: h.LastReport,
+		}); err != nil {
+			slog.Error("watchdog: render message", "host", h.Hostname, "err", err)
+			continue
+		}
+		if err := out.publish(n); err != nil {
+			slog.Error("watchdog: enqueue message", "host", h.Hostname, "err", err)
+			continue
+		}
+
+		_, err = st.UpdateHost(h.Hostname, func(cur *store.Host) bool {
+			// за время проверки мог прийти новый отчёт
+			if !cur.LastReport.Equal(h.LastReport) {
+				return false
+			}
+			cur.MissingSince = expected
+			return true
+		})
+		if err != nil {
+			slog.Error("watchdog: save host", "host", h.Hostname, "err", err)
+			continue
+		}
+		slog.Warn("host missed report", "host", h.Hostname, "expected", expected)
+	}
+}
@@ -0,0 +1,73 @@
+// Package alert реализует машину состояний оповещений по устройствам: уведомление
+// отправляется только при смене уровня, с периодическими напоминаниями о
+// нерешённых проблемах и сообщением о восстановлении.
+package alert
+
+import (
+	"time"
+
+	"github.com/covrom/smart-control/internal/store"
+)
+
+// Event — что нужно сообщить по итогам очередного отчёта
+type Event string
+
+const (
+	EventNone      Event = ""          // ничего не сообщать
+	EventProblem   Event = "problem"   // новая проблема или смена её уровня
+	EventReminder  Event = "reminder"  // проблема сохраняется дольше интервала напоминаний
+	EventRecovered Event = "recovered" // устройство вернулось в норму
+)
+
+// IsProblem сообщает, требует ли уровень внимания
+func IsProblem(l store.Level) bool {
+	return l == store.LevelWarning || l == store.LevelCritical
+}
+
+// Next вычисляет новое состояние по уровню level из отчёта от ts и событие,
+// о котором нужно уведомить. remind — интервал напоминаний, 0 — без напоминаний.
+//
+// Уровень unknown (анализ не получен) не отменяет известную проблему: состояние
+// сохраняется до следующего отчёта с определённым уровнем. Подтверждение
+// проблемы действует до смены уровня и отключает напоминания; отложенное
+// устройство до SnoozedUntil не сообщает о проблемах того же или меньшего
+// уровня, но сообщает об ухудшении (откладывание при этом снимается) и о
+// восстановлении; отключённое устройство не сообщает ни о чём.
+func Next(prev store.AlertState, level store.Level, ts time.Time, remind time.Duration) (store.AlertState, Event) {
+	next := prev
+	next.ReportAt = ts
+
+	if level == store.LevelUnknown && IsProblem(prev.Level) {
+		return next, EventNone
+	}
+
+	ev := EventNone
+	if level != prev.Level {
+		next.Level = level
+		next.Since = ts
+		next.AckedBy, next.AckedAt = "", time.Time{}
+		// откладывание относится к прежнему уровню: об ухудшении сообщаем
+		if IsProblem(prev.Level) && level.Rank() > prev.Level.Rank() {
+			next.SnoozedUntil = time.Time{}
+		}
+		switch {
+		case IsProblem(level):
+			ev = EventProblem
+		case IsProblem(prev.Level) && level == store.LevelOK:
+			ev = EventRecovered
+		}
+	} else if IsProblem(level) && remind > 0 && ts.Sub(prev.NotifiedAt) >= remind && next.AckedAt.IsZero() {
+		ev = EventReminder
+	}
+
+	switch {
+	case ev == EventNone:
+		return next, ev
+	case next.Muted:
+		return next, EventNone
+	case ev != EventRecovered && ts.Before(next.SnoozedUntil):
+		return next, EventNone
+	}
+	next.NotifiedAt = ts
+	return next, ev
+}
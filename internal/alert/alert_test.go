@@ -0,0 +1,107 @@
+package alert
+
+import (
+	"testing"
+	"time"
+
+	"github.com/covrom/smart-control/internal/store"
+)
+
+func TestNext(t *testing.T) {
+	day := 24 * time.Hour
+	t0 := time.Date(2025, 1, 1, 23, 55, 0, 0, time.UTC)
+
+	steps := []struct {
+		level store.Level
+		want  Event
+	}{
+		{store.LevelOK, EventNone},          // первый отчёт, всё в порядке
+		{store.LevelOK, EventNone},          // без изменений — тишина
+		{store.LevelWarning, EventProblem},  // появилась проблема
+		{store.LevelWarning, EventNone},     // раньше интервала напоминаний
+		{store.LevelUnknown, EventNone},     // анализ не получен — проблема не снимается
+		{store.LevelWarning, EventReminder}, // прошло двое суток с уведомления
+		{store.LevelCritical, EventProblem}, // ухудшение
+		{store.LevelWarning, EventProblem},  // улучшение, но проблема остаётся
+		{store.LevelOK, EventRecovered},     // восстановление
+		{store.LevelUnknown, EventNone},     // из нормы в неизвестность — тишина
+		{store.LevelOK, EventNone},          // и обратно
+		{store.LevelCritical, EventProblem},
+	}
+
+	var st store.AlertState
+	for i, s := range steps {
+		ts := t0.Add(time.Duration(i) * day)
+		var ev Event
+		st, ev = Next(st, s.level, ts, 2*day)
+		if ev != s.want {
+			t.Fatalf("step %d (%s): event %q, want %q", i, s.level, ev, s.want)
+		}
+		if st.ReportAt != ts {
+			t.Fatalf("step %d: ReportAt not updated", i)
+		}
+	}
+	if st.Level != store.LevelCritical || st.Since != t0.Add(11*day) {
+		t.Fatalf("unexpected final state %+v", st)
+	}
+}
+
+func TestNextNoReminders(t *testing.T) {
+	t0 := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	st, ev := Next(store.AlertState{}, store.LevelCritical, t0, 0)
+	if ev != EventProblem {
+		t.Fatalf("event %q", ev)
+	}
+	if _, ev = Next(st, store.LevelCritical, t0.Add(365*24*time.Hour), 0); ev != EventNone {
+		t.Fatalf("reminder with remind=0: %q", ev)
+	}
+}
+
+func TestNextUserControls(t *testing.T) {
+	day := 24 * time.Hour
+	t0 := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	st, _ := Next(store.AlertState{}, store.LevelWarning, t0, day)
+
+	// подтверждённая проблема не напоминает о себе
+	st.AckedBy, st.AckedAt = "admin", t0
+	if _, ev := Next(st, store.LevelWarning, t0.Add(2*day), day); ev != EventNone {
+		t.Fatalf("reminder after ack: %q", ev)
+	}
+	// но смена уровня сбрасывает подтверждение
+	next, ev := Next(st, store.LevelCritical, t0.Add(2*day), day)
+	if ev != EventProblem || next.AckedBy != "" {
+		t.Fatalf("escalation after ack: %q %+v", ev, next)
+	}
+
+	// отложенная проблема молчит до срока, восстановление сообщается
+	st = next
+	st.SnoozedUntil = t0.Add(7 * day)
+	if next, ev = Next(st, store.LevelCritical, t0.Add(4*day), day); ev != EventNone || next.NotifiedAt != st.NotifiedAt {
+		t.Fatalf("reminder while snoozed: %q", ev)
+	}
+	if _, ev = Next(st, store.LevelCritical, t0.Add(8*day), day); ev != EventReminder {
+		t.Fatalf("no reminder after snooze: %q", ev)
+	}
+	if _, ev = Next(st, store.LevelOK, t0.Add(4*day), day); ev != EventRecovered {
+		t.Fatalf("no recovery while snoozed: %q", ev)
+	}
+
+	// ухудшение отложенной проблемы сообщается и снимает откладывание
+	warn, _ := Next(store.AlertState{}, store.LevelWarning, t0, day)
+	warn.SnoozedUntil = t0.Add(7 * day)
+	if next, ev = Next(warn, store.LevelCritical, t0.Add(day), day); ev != EventProblem || !next.SnoozedUntil.IsZero() {
+		t.Fatalf("escalation while snoozed: %q %+v", ev, next)
+	}
+	crit, _ := Next(store.AlertState{}, store.LevelCritical, t0, day)
+	crit.SnoozedUntil = t0.Add(7 * day)
+	if _, ev = Next(crit, store.LevelWarning, t0.Add(day), day); ev != EventNone {
+		t.Fatalf("de-escalation while snoozed: %q", ev)
+	}
+
+	// отключённое устройство молчит, но состояние отслеживается
+	st.Muted = true
+	if next, ev = Next(st, store.LevelOK, t0.Add(4*day), day); ev != EventNone || next.Level != store.LevelOK {
+		t.Fatalf("muted: %q %+v", ev, next)
+	}
+}
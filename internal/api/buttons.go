@@ -0,0 +1,51 @@
+package api
+
+import (
+	"strings"
+
+	"github.com/covrom/smart-control/internal/i18n"
+	tele "gopkg.in/telebot.v3"
+)
+
+// Кнопки уведомлений о состоянии устройства. Данные кнопки — "<хост>|<устройство>",
+// текст — "button.<Unique>" из каталога i18n на языке чата.
+var (
+	BtnAck    = tele.Btn{Unique: "ack"}
+	BtnSnooze = tele.Btn{Unique: "snooze"}
+	BtnMute   = tele.Btn{Unique: "mute"}
+	BtnUnmute = tele.Btn{Unique: "unmute"}
+	BtnRaw    = tele.Btn{Unique: "raw"}
+)
+
+// maxCallbackData — ограничение Telegram на длину данных кнопки
+const maxCallbackData = 64
+
+// AlertMarkup возвращает клавиатуру уведомления об устройстве на языке lang.
+// Если имена хоста и устройства не помещаются в данные кнопки, клавиатуры нет.
+func AlertMarkup(host, device string, muted bool, lang i18n.Lang) *tele.ReplyMarkup {
+	data := host + "|" + device
+	// "\f" + unique + "|" + data
+	if len(data)+len(BtnSnooze.Unique)+2 > maxCallbackData {
+		return nil
+	}
+
+	m := &tele.ReplyMarkup{}
+	btn := func(b tele.Btn) tele.Btn {
+		b.Data, b.Text = data, lang.T("button."+b.Unique)
+		return b
+	}
+	mute := btn(BtnMute)
+	if muted {
+		mute = btn(BtnUnmute)
+	}
+	m.Inline(
+		m.Row(btn(BtnAck), btn(BtnSnooze)),
+		m.Row(mute, btn(BtnRaw)),
+	)
+	return m
+}
+
+// ParseAlertData разбирает данные кнопки уведомления
+func ParseAlertData(data string) (host, device string, ok bool) {
+	return strings.Cut(data, "|")
+}
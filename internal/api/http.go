@@ -9,24 +9,38 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/covrom/smart-control/internal/i18n"
+	"github.com/covrom/smart-control/internal/llmdesc"
+	"github.com/covrom/smart-control/internal/queue"
 	"github.com/covrom/smart-control/internal/smartdata"
+	"github.com/covrom/smart-control/internal/store"
+	"github.com/covrom/smart-control/internal/web"
 )
 
-func NewHttpServer(token string, ch chan<- smartdata.CommonSMARTReport) *http.Server {
+// NewHttpServer запускает HTTP-сервер приёма отчётов агентов (token),
+// read-only API, метрики Prometheus и веб-интерфейс (apiToken).
+// messages — очередь сообщений Telegram, её недоставленные сообщения доступны
+// в API; размеры всех очередей и счётчики обращений к LLM выводятся в метриках.
+// Веб-интерфейс выводится на языке lang.
+func NewHttpServer(token, apiToken string, reports, messages, notifications *queue.Queue, st *store.Store, llm *llmdesc.LLMSmartDescriber, lang i18n.Lang) *http.Server {
+	mux := http.NewServeMux()
 	srv := &http.Server{
 		Addr:         ":8000",
-		Handler:      nil,
+		Handler:      mux,
 		ReadTimeout:  60 * time.Second,
 		WriteTimeout: 60 * time.Second,
 	}
 
-	http.Handle("POST /smart/report", handleSmartReport(token, ch))
+	mux.Handle("POST /smart/report", handleSmartReport(token, reports))
+	registerQueryAPI(mux, apiToken, st, messages)
+	registerMetrics(mux, apiToken, st, llm, reports, messages, notifications)
+	web.Register(mux, apiToken, st, lang)
 	go srv.ListenAndServe()
 	slog.Info("http server started")
 	return srv
 }
 
-func handleSmartReport(token string, chReps chan<- smartdata.CommonSMARTReport) http.Handler {
+func handleSmartReport(token string, reports *queue.Queue) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		expectedAuthHeader := fmt.Sprintf("Bearer %s", token)
 
@@ -62,10 +76,20 @@ func handleSmartReport(token string, chReps chan<- smartdata.CommonSMARTReport)
 			return
 		}
 
-		// 5. Сохранение
-		chReps <- report
+		// 5. Сохранение в очередь анализа (до ответа агенту, чтобы отчёт не потерялся)
+		if _, err := reports.Enqueue(ReportKey(report), report); err != nil {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			slog.Error("Enqueue report error", "host", report.Hostname, "err", err)
+			return
+		}
 
 		// 6. Ответ
 		slog.Info("Received info", "from", r.RemoteAddr, "host", report.Hostname, "devices", len(report.Devices))
 	})
 }
+
+// ReportKey — ключ идемпотентности отчёта: повторная отправка того же отчёта
+// агентом не приведёт к повторному анализу
+func ReportKey(report smartdata.CommonSMARTReport) string {
+	return report.Hostname + "@" + report.Timestamp.UTC().Format(time.RFC3339Nano)
+}
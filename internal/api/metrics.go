@@ -0,0 +1,81 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"time"
+
+	"github.com/covrom/smart-control/internal/llmdesc"
+	"github.com/covrom/smart-control/internal/metrics"
+	"github.com/covrom/smart-control/internal/queue"
+	"github.com/covrom/smart-control/internal/store"
+)
+
+// registerMetrics регистрирует /metrics в формате Prometheus по последним
+// снимкам устройств, счётчикам обращений к LLM и размерам очередей
+func registerMetrics(mux *http.ServeMux, token string, st *store.Store, llm *llmdesc.LLMSmartDescriber, queues ...*queue.Queue) {
+	mux.Handle("GET /metrics", requireToken(token, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hosts, err := st.Hosts()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		var mhosts []metrics.Host
+		var mdevices []metrics.Device
+		for _, h := range hosts {
+			mhosts = append(mhosts, metrics.Host{
+				Host:       h.Hostname,
+				LastReport: h.LastReport,
+				Devices:    len(h.Devices),
+				Missing:    !h.MissingSince.IsZero(),
+			})
+			for _, d := range h.Devices {
+				snap, ok, err := st.Latest(h.Hostname, d)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				if !ok {
+					continue
+				}
+				mdevices = append(mdevices, metrics.Device{
+					Host:    h.Hostname,
+					Device:  d,
+					Metrics: snap.Metrics,
+					Verdict: snap.Analysis.Verdict().Rank(),
+				})
+			}
+		}
+
+		var buf bytes.Buffer
+		if err := metrics.Write(&buf, mhosts, mdevices, time.Now()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		var mqueues []metrics.Queue
+		for _, q := range queues {
+			pending, dead, err := q.Len()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			mqueues = append(mqueues, metrics.Queue{Name: q.Name(), Pending: pending, Dead: dead})
+		}
+		if err := metrics.WriteQueues(&buf, mqueues); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		stats := llm.Stats()
+		mllm := metrics.LLM{Described: stats.Described, Reused: stats.Reused}
+		for _, p := range stats.Providers {
+			mllm.Providers = append(mllm.Providers, metrics.LLMProvider(p))
+		}
+		if err := metrics.WriteLLM(&buf, mllm); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Write(buf.Bytes())
+	})))
+}
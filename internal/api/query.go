@@ -0,0 +1,279 @@
+package api
+
+import (
+	_ "embed"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/covrom/smart-control/internal/queue"
+	"github.com/covrom/smart-control/internal/store"
+)
+
+//go:embed openapi.json
+var openAPISpec []byte
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 500
+)
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("write json response", "err", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+// requireToken пропускает только запросы с заголовком Authorization: Bearer <token>
+func requireToken(token string, h http.Handler) http.Handler {
+	expected := "Bearer " + token
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token == "" || r.Header.Get("Authorization") != expected {
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			slog.Error("Unauthorized request", "from", r.RemoteAddr, "path", r.URL.Path)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// registerQueryAPI регистрирует read-only JSON API /api/v1
+func registerQueryAPI(mux *http.ServeMux, token string, st *store.Store, messages *queue.Queue) {
+	mux.HandleFunc("GET /api/v1/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(openAPISpec)
+	})
+
+	handle := func(pattern string, h http.HandlerFunc) {
+		mux.Handle(pattern, requireToken(token, h))
+	}
+
+	handle("GET /api/v1/hosts", func(w http.ResponseWriter, r *http.Request) {
+		hosts, err := st.Hosts()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if hosts == nil {
+			hosts = []store.Host{}
+		}
+		writeJSON(w, http.StatusOK, hosts)
+	})
+
+	handle("GET /api/v1/hosts/{host}", func(w http.ResponseWriter, r *http.Request) {
+		h, ok := lookupHost(w, st, r.PathValue("host"))
+		if !ok {
+			return
+		}
+		devices, err := st.DeviceSummaries(h)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, struct {
+			store.Host
+			DeviceDetails []store.DeviceSummary `json:"device_details"`
+		}{h, devices})
+	})
+
+	handle("GET /api/v1/hosts/{host}/devices", func(w http.ResponseWriter, r *http.Request) {
+		h, ok := lookupHost(w, st, r.PathValue("host"))
+		if !ok {
+			return
+		}
+		devices, err := st.DeviceSummaries(h)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, devices)
+	})
+
+	handle("GET /api/v1/hosts/{host}/devices/{device}", func(w http.ResponseWriter, r *http.Request) {
+		host, device, ok := lookupDevice(w, st, r)
+		if !ok {
+			return
+		}
+		snap, found, err := st.Latest(host, device)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if !found {
+			writeError(w, http.StatusNotFound, "no snapshots")
+			return
+		}
+		writeJSON(w, http.StatusOK, withRaw(snap, r))
+	})
+
+	handle("GET /api/v1/hosts/{host}/devices/{device}/history", func(w http.ResponseWriter, r *http.Request) {
+		host, device, ok := lookupDevice(w, st, r)
+		if !ok {
+			return
+		}
+		from, to, ok := parseRange(w, r)
+		if !ok {
+			return
+		}
+		offset, limit, ok := parsePage(w, r)
+		if !ok {
+			return
+		}
+		history, err := st.History(host, device, from, to)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		page := []store.Snapshot{}
+		for i := offset; i < len(history) && i < offset+limit; i++ {
+			page = append(page, withRaw(history[i], r))
+		}
+		writeJSON(w, http.StatusOK, struct {
+			Total  int              `json:"total"`
+			Offset int              `json:"offset"`
+			Limit  int              `json:"limit"`
+			Items  []store.Snapshot `json:"items"`
+		}{len(history), offset, limit, page})
+	})
+
+	handle("GET /api/v1/hosts/{host}/devices/{device}/series", func(w http.ResponseWriter, r *http.Request) {
+		host, device, ok := lookupDevice(w, st, r)
+		if !ok {
+			return
+		}
+		from, to, ok := parseRange(w, r)
+		if !ok {
+			return
+		}
+		metrics := r.URL.Query()["metric"]
+		if len(metrics) == 0 {
+			writeError(w, http.StatusBadRequest, "metric is required")
+			return
+		}
+		history, err := st.History(host, device, from, to)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, store.Series(history, metrics...))
+	})
+
+	// сообщения, которые не удалось доставить, с последней ошибкой;
+	// вернуть их в очередь можно командой tgsmctl queue requeue
+	handle("GET /api/v1/outbox/dead", func(w http.ResponseWriter, r *http.Request) {
+		jobs, err := messages.Dead()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		items := []deadMessage{}
+		for _, job := range jobs {
+			var msg Message
+			if err := job.Decode(&msg); err != nil {
+				msg.Text = string(job.Payload)
+			}
+			items = append(items, deadMessage{
+				ID:        job.ID,
+				Key:       job.Key,
+				Attempts:  job.Attempts,
+				CreatedAt: job.CreatedAt,
+				FailedAt:  job.FailedAt,
+				LastError: job.LastError,
+				Message:   msg,
+			})
+		}
+		writeJSON(w, http.StatusOK, items)
+	})
+}
+
+// deadMessage — недоставленное сообщение очереди уведомлений
+type deadMessage struct {
+	ID        string    `json:"id"`
+	Key       string    `json:"key"`
+	Attempts  int       `json:"attempts"`
+	CreatedAt time.Time `json:"created_at"`
+	FailedAt  time.Time `json:"failed_at"`
+	LastError string    `json:"last_error"`
+	Message   Message   `json:"message"`
+}
+
+func lookupHost(w http.ResponseWriter, st *store.Store, name string) (store.Host, bool) {
+	h, ok, err := st.Host(name)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return h, false
+	}
+	if !ok {
+		writeError(w, http.StatusNotFound, "host not found")
+		return h, false
+	}
+	return h, true
+}
+
+func lookupDevice(w http.ResponseWriter, st *store.Store, r *http.Request) (string, string, bool) {
+	host := r.PathValue("host")
+	device, ok, err := st.FindDevice(host, r.PathValue("device"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return "", "", false
+	}
+	if !ok {
+		writeError(w, http.StatusNotFound, "device not found")
+		return "", "", false
+	}
+	return host, device, true
+}
+
+// withRaw убирает сырой вывод smartctl из снимка, если не запрошен параметр raw=1
+func withRaw(snap store.Snapshot, r *http.Request) store.Snapshot {
+	if r.URL.Query().Get("raw") != "1" {
+		snap.Device.SMARTData = ""
+		snap.Metrics.Attributes = nil
+	}
+	return snap
+}
+
+func parseRange(w http.ResponseWriter, r *http.Request) (from, to time.Time, ok bool) {
+	q := r.URL.Query()
+	var err error
+	if s := q.Get("from"); s != "" {
+		if from, err = time.Parse(time.RFC3339, s); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid from: "+err.Error())
+			return from, to, false
+		}
+	}
+	if s := q.Get("to"); s != "" {
+		if to, err = time.Parse(time.RFC3339, s); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid to: "+err.Error())
+			return from, to, false
+		}
+	}
+	return from, to, true
+}
+
+func parsePage(w http.ResponseWriter, r *http.Request) (offset, limit int, ok bool) {
+	q := r.URL.Query()
+	limit = defaultPageLimit
+	var err error
+	if s := q.Get("offset"); s != "" {
+		if offset, err = strconv.Atoi(s); err != nil || offset < 0 {
+			writeError(w, http.StatusBadRequest, "invalid offset")
+			return 0, 0, false
+		}
+	}
+	if s := q.Get("limit"); s != "" {
+		if limit, err = strconv.Atoi(s); err != nil || limit <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid limit")
+			return 0, 0, false
+		}
+	}
+	return offset, min(limit, maxPageLimit), true
+}
@@ -1,70 +1,226 @@
 package api
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"os"
 	"strconv"
+	"strings"
 	"sync"
-	"unicode"
+	"time"
 
+	"github.com/covrom/smart-control/internal/i18n"
+	"github.com/covrom/smart-control/internal/queue"
+	"github.com/covrom/smart-control/internal/tghtml"
 	tele "gopkg.in/telebot.v3"
 )
 
-func sendMessage(b *tele.Bot, chatID, message string) {
-	i, err := strconv.ParseInt(chatID, 10, 64)
+// Message — задача очереди уведомлений
+type Message struct {
+	Text string `json:"text"`
+	// HTML — текст размечен для Telegram (см. пакет tghtml), иначе это простой текст
+	HTML bool `json:"html,omitempty"`
+	// Host и Device задаются для уведомлений о состоянии устройства:
+	// к ним добавляются кнопки управления оповещениями
+	Host   string `json:"host,omitempty"`
+	Device string `json:"device,omitempty"`
+	// Muted — оповещения по устройству отключены: вместо кнопки отключения
+	// выводится кнопка включения
+	Muted bool `json:"muted,omitempty"`
+	// ChatID и ThreadID — получатель; нулевой ChatID — чат по умолчанию
+	ChatID   int64 `json:"chat_id,omitempty"`
+	ThreadID int   `json:"thread_id,omitempty"`
+	// Photo — PNG, отправляемый после текста (например, график трендов)
+	Photo []byte `json:"photo,omitempty"`
+	// Lang — язык кнопок, пустой — язык по умолчанию
+	Lang i18n.Lang `json:"lang,omitempty"`
+}
+
+// Интервалы между сообщениями в один чат: Telegram допускает около одного
+// сообщения в секунду в личный чат и около 20 в минуту в группу
+const (
+	privateChatInterval = time.Second
+	groupChatInterval   = 3 * time.Second
+)
+
+var errChatBlocked = errors.New("earlier message to this chat is not delivered yet")
+
+// tgSender отправляет сообщения очереди по одному, соблюдая ограничения
+// частоты Telegram и порядок сообщений в каждом чате
+type tgSender struct {
+	b           *tele.Bot
+	defaultChat string
+	messages    *queue.Queue
+	lastSent    map[int64]time.Time
+	// blocked — задача, которую не удалось доставить в чат: более поздние
+	// сообщения в этот чат ждут её, чтобы не обогнать
+	blocked map[int64]string
+}
+
+func (s *tgSender) chat(msg Message) (*tele.Chat, error) {
+	if msg.ChatID != 0 {
+		return &tele.Chat{ID: msg.ChatID}, nil
+	}
+	i, err := strconv.ParseInt(s.defaultChat, 10, 64)
 	if err != nil {
-		slog.Error("Error parsing chatID", "err", err)
+		return nil, fmt.Errorf("parse chatID: %w", err)
+	}
+	return &tele.Chat{ID: i}, nil
+}
+
+// wait выдерживает интервал с прошлой отправки в чат
+func (s *tgSender) wait(ctx context.Context, chatID int64) error {
+	interval := privateChatInterval
+	if chatID < 0 {
+		interval = groupChatInterval
+	}
+	d := time.Until(s.lastSent[chatID].Add(interval))
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// handle отправляет сообщение задачи. Пока более раннее сообщение в тот же
+// чат не доставлено, задача откладывается без учёта попытки.
+func (s *tgSender) handle(ctx context.Context, job queue.Job) error {
+	var msg Message
+	if err := job.Decode(&msg); err != nil {
+		return queue.Permanent(err)
+	}
+	chat, err := s.chat(msg)
+	if err != nil {
+		return queue.Permanent(err)
+	}
+
+	if err := s.waitBlocked(chat.ID, job); err != nil {
+		return err
+	}
+
+	if err := s.send(ctx, chat, msg, job); err != nil {
+		s.blocked[chat.ID] = job.ID
+		return err
+	}
+	if s.blocked[chat.ID] == job.ID {
+		delete(s.blocked, chat.ID)
 	}
-	chat := &tele.Chat{ID: i}
+	return nil
+}
 
-	// Преобразуем текст в rune slice для корректной работы с символами
-	textRunes := []rune(message)
+// waitBlocked откладывает задачу, пока в очереди ждёт повтора более раннее
+// недоставленное сообщение в тот же чат. Доставленная или ушедшая в dead
+// задача больше не задерживает чат.
+func (s *tgSender) waitBlocked(chatID int64, job queue.Job) error {
+	id, ok := s.blocked[chatID]
+	if !ok || id == job.ID {
+		return nil
+	}
+	first, dead, err := s.messages.Get(id)
+	if errors.Is(err, os.ErrNotExist) || (err == nil && dead) {
+		delete(s.blocked, chatID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if first.ID > job.ID {
+		return nil
+	}
+	// более раннюю задачу очередь возьмёт первой, как только подойдёт её срок
+	return queue.RetryAfter(errChatBlocked, max(time.Until(first.NextRunAt), time.Second))
+}
 
-	// Разбиваем текст на части по 3000 символов
-	const maxLen = 3000
-	var parts []string
+// send отправляет сообщение по частям, начиная с job.Progress, и сохраняет
+// прогресс после каждой части: при повторе уже доставленные части не
+// отправляются второй раз, и порядок частей не нарушается. Изображение
+// отправляется последней частью.
+func (s *tgSender) send(ctx context.Context, chat *tele.Chat, msg Message, job queue.Job) error {
+	html := msg.Text
+	if !msg.HTML {
+		html = tghtml.Escape(msg.Text)
+	}
+	parts := tghtml.Split(html, tghtml.MaxMessageLen)
+	total := len(parts)
+	if len(msg.Photo) > 0 {
+		total++
+	}
 
-	for len(textRunes) > maxLen {
-		// Ищем последний пробел перед лимитом
-		lastSpace := -1
-		for i := maxLen - 1; i >= 0; i-- {
-			if unicode.IsSpace(textRunes[i]) {
-				lastSpace = i
-				break
+	// кнопки — у последней текстовой части
+	for i := job.Progress; i < total; i++ {
+		if err := s.wait(ctx, chat.ID); err != nil {
+			return err
+		}
+		opts := &tele.SendOptions{ThreadID: msg.ThreadID, ParseMode: tele.ModeHTML}
+		if i == len(parts) {
+			_, err := s.b.Send(chat, &tele.Photo{File: tele.FromReader(bytes.NewReader(msg.Photo))}, opts)
+			s.lastSent[chat.ID] = time.Now()
+			if err != nil {
+				return sendError(err)
 			}
+			continue
+		}
+		part := parts[i]
+		if i == len(parts)-1 && msg.Device != "" {
+			opts.ReplyMarkup = AlertMarkup(msg.Host, msg.Device, msg.Muted, msg.Lang.Or(i18n.Default))
+		}
+		_, err := s.b.Send(chat, part, opts)
+		if err != nil && strings.Contains(err.Error(), "can't parse entities") {
+			// разметка не принята — отправляем ту же часть простым текстом
+			slog.Error("telegram: invalid html, sending as plain text", "err", err)
+			opts.ParseMode = tele.ModeDefault
+			_, err = s.b.Send(chat, tghtml.Plain(part), opts)
+		}
+		s.lastSent[chat.ID] = time.Now()
+		if err != nil {
+			return sendError(err)
 		}
-		if lastSpace > 0 {
-			parts = append(parts, string(textRunes[:lastSpace]))
-			textRunes = textRunes[lastSpace+1:]
-		} else {
-			parts = append(parts, string(textRunes[:maxLen]))
-			textRunes = textRunes[maxLen:]
+		if i < total-1 {
+			if err := s.messages.SaveProgress(job, i+1); err != nil {
+				slog.Error("telegram: save progress", "id", job.ID, "err", err)
+			}
 		}
 	}
+	return nil
+}
 
-	if len(textRunes) > 0 {
-		parts = append(parts, string(textRunes))
+// sendError классифицирует ошибку Telegram: при ограничении частоты задача
+// откладывается на указанный Telegram срок без учёта попытки, в недоступный
+// чат повторять отправку бессмысленно
+func sendError(err error) error {
+	var flood tele.FloodError
+	if errors.As(err, &flood) {
+		return queue.RetryAfter(fmt.Errorf("telegram: %w", err), time.Duration(flood.RetryAfter)*time.Second)
 	}
-
-	// Отправляем каждую часть
-	for _, part := range parts {
-		_, err = b.Send(chat, part)
-		if err != nil {
-			slog.Error("Telegram error", "err", err)
-			return
+	for _, perm := range []*tele.Error{tele.ErrChatNotFound, tele.ErrBlockedByUser, tele.ErrKickedFromGroup,
+		tele.ErrKickedFromSuperGroup, tele.ErrKickedFromChannel, tele.ErrUserIsDeactivated} {
+		if errors.Is(err, perm) {
+			return queue.Permanent(fmt.Errorf("telegram: %w", err))
 		}
 	}
+	return fmt.Errorf("telegram: %w", err)
 }
 
-func TgSendWorker(ctx context.Context, b *tele.Bot, telegramChatID string, ch <-chan string, wg *sync.WaitGroup) {
-	defer wg.Done()
+// TgSendWorker отправляет в Telegram сообщения из очереди уведомлений.
+// Неотправленные сообщения остаются в очереди и повторяются по её политике,
+// ограничение частоты Telegram (retry_after) соблюдается без расхода попыток.
+func TgSendWorker(ctx context.Context, b *tele.Bot, telegramChatID string, messages *queue.Queue, wg *sync.WaitGroup) {
 	slog.Info("tgSendWorker started")
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case msg := <-ch:
-			sendMessage(b, telegramChatID, msg)
-		}
+	s := &tgSender{
+		b:           b,
+		defaultChat: telegramChatID,
+		messages:    messages,
+		lastSent:    make(map[int64]time.Time),
+		blocked:     make(map[int64]string),
 	}
+	messages.Run(ctx, wg, s.handle)
 }
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/covrom/smart-control/internal/queue"
+)
+
+func TestTgSender_waitBlocked(t *testing.T) {
+	q, err := queue.Open(t.TempDir(), queue.Policy{MaxAttempts: 3, MinBackoff: time.Hour, MaxBackoff: time.Hour, KeepDone: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"first", "second"} {
+		if _, err := q.Enqueue(key, Message{Text: key, ChatID: -100}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	jobs, err := q.Pending()
+	if err != nil || len(jobs) != 2 {
+		t.Fatalf("pending = %v, %v", jobs, err)
+	}
+	first, second := jobs[0], jobs[1]
+	s := &tgSender{messages: q, blocked: map[int64]string{-100: first.ID}}
+
+	// первое сообщение ждёт повтора: второе откладывается
+	if err := s.waitBlocked(-100, second); err == nil || !errors.Is(err, errChatBlocked) {
+		t.Fatalf("pending block: err = %v", err)
+	}
+	// само заблокированное сообщение и сообщения в другие чаты не ждут
+	if err := s.waitBlocked(-100, first); err != nil {
+		t.Errorf("blocking job: err = %v", err)
+	}
+	if err := s.waitBlocked(-200, second); err != nil {
+		t.Errorf("other chat: err = %v", err)
+	}
+
+	// первое сообщение ушло в dead: блокировка снимается
+	ctx, cancel := context.WithCancel(context.Background())
+	q.OnDead(func(queue.Job) { cancel() })
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go q.Run(ctx, wg, func(ctx context.Context, job queue.Job) error {
+		if job.ID == first.ID {
+			return queue.Permanent(errors.New("chat not found"))
+		}
+		return queue.RetryAfter(errChatBlocked, time.Hour)
+	})
+	wg.Wait()
+	if err := s.waitBlocked(-100, second); err != nil {
+		t.Errorf("dead block: err = %v", err)
+	}
+	if _, ok := s.blocked[-100]; ok {
+		t.Error("dead block is not cleared")
+	}
+
+	// доставленное и удалённое сообщение тоже не блокирует чат
+	s.blocked[-100] = "missing"
+	if err := s.waitBlocked(-100, second); err != nil {
+		t.Errorf("missing block: err = %v", err)
+	}
+	if _, ok := s.blocked[-100]; ok {
+		t.Error("missing block is not cleared")
+	}
+}
@@ -0,0 +1,272 @@
+// Package chart рисует графики показателей SMART в PNG без внешних
+// зависимостей: по панели с собственной шкалой на каждый показатель,
+// общая ось времени, подписи растровым шрифтом.
+package chart
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Point — значение показателя в момент времени
+type Point struct {
+	Time  time.Time
+	Value float64
+}
+
+// Panel — график одного показателя
+type Panel struct {
+	Title  string
+	Points []Point
+}
+
+// Размеры изображения в точках
+const (
+	width       = 800
+	margin      = 16
+	scale       = 2 // масштаб растрового шрифта
+	titleHeight = 40
+	panelHeight = 190
+	axisWidth   = 80 // место под подписи значений слева от графика
+	xTicks      = 4
+	yTicks      = 4
+)
+
+var (
+	colorBackground = color.RGBA{255, 255, 255, 255}
+	colorText       = color.RGBA{33, 33, 33, 255}
+	colorAxis       = color.RGBA{120, 120, 120, 255}
+	colorGrid       = color.RGBA{225, 225, 225, 255}
+	palette         = []color.RGBA{
+		{211, 47, 47, 255},
+		{25, 118, 210, 255},
+		{56, 142, 60, 255},
+		{245, 124, 0, 255},
+		{123, 31, 162, 255},
+	}
+)
+
+// Render рисует панели одну под другой и возвращает PNG. Ось времени —
+// от from до to; при нулевых границах они берутся по точкам. Панели без
+// точек пропускаются; если не осталось ни одной, возвращается nil.
+func Render(title string, from, to time.Time, panels []Panel) ([]byte, error) {
+	panels = slices.DeleteFunc(slices.Clone(panels), func(p Panel) bool { return len(p.Points) == 0 })
+	if len(panels) == 0 {
+		return nil, nil
+	}
+	if from.IsZero() || to.IsZero() {
+		for _, p := range panels {
+			for _, pt := range p.Points {
+				if from.IsZero() || pt.Time.Before(from) {
+					from = pt.Time
+				}
+				if to.IsZero() || pt.Time.After(to) {
+					to = pt.Time
+				}
+			}
+		}
+	}
+	if !to.After(from) {
+		from, to = from.Add(-time.Hour), to.Add(time.Hour)
+	}
+
+	height := margin + titleHeight + len(panels)*panelHeight + margin
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{colorBackground}, image.Point{}, draw.Src)
+
+	text(img, margin, margin, title, colorText)
+	for i, p := range panels {
+		top := margin + titleHeight + i*panelHeight
+		drawPanel(img, image.Rect(margin, top, width-margin, top+panelHeight), from, to, p, palette[i%len(palette)])
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// drawPanel рисует заголовок, сетку с подписями и линию показателя в r
+func drawPanel(img *image.RGBA, r image.Rectangle, from, to time.Time, p Panel, c color.RGBA) {
+	text(img, r.Min.X, r.Min.Y, p.Title, c)
+
+	lineH := (glyphH + 2) * scale
+	plot := image.Rect(r.Min.X+axisWidth, r.Min.Y+lineH+6, r.Max.X-8, r.Max.Y-lineH-14)
+
+	lo, hi, step := valueRange(p.Points)
+	xOf := func(t time.Time) int {
+		return plot.Min.X + int(float64(plot.Dx())*float64(t.Sub(from))/float64(to.Sub(from)))
+	}
+	yOf := func(v float64) int {
+		return plot.Max.Y - int(math.Round(float64(plot.Dy())*(v-lo)/(hi-lo)))
+	}
+
+	// сетка и подписи значений
+	for i := 0; lo+float64(i)*step <= hi+step/2; i++ {
+		v := lo + float64(i)*step
+		y := yOf(v)
+		hline(img, plot.Min.X, plot.Max.X, y, colorGrid)
+		label := FormatValue(v)
+		text(img, plot.Min.X-8-textWidth(label), y-glyphH*scale/2, label, colorAxis)
+	}
+	// подписи времени
+	layout := "02.01"
+	if to.Sub(from) < 48*time.Hour {
+		layout = "15:04"
+	}
+	for i := 0; i <= xTicks; i++ {
+		t := from.Add(time.Duration(float64(to.Sub(from)) * float64(i) / xTicks))
+		x := xOf(t)
+		vline(img, x, plot.Min.Y, plot.Max.Y, colorGrid)
+		label := t.Local().Format(layout)
+		lx := min(max(x-textWidth(label)/2, plot.Min.X), r.Max.X-textWidth(label))
+		text(img, lx, plot.Max.Y+8, label, colorAxis)
+	}
+	hline(img, plot.Min.X, plot.Max.X, plot.Max.Y, colorAxis)
+	vline(img, plot.Min.X, plot.Min.Y, plot.Max.Y, colorAxis)
+
+	points := slices.SortedFunc(slices.Values(p.Points), func(a, b Point) int { return a.Time.Compare(b.Time) })
+	for i, pt := range points {
+		x, y := xOf(pt.Time), yOf(pt.Value)
+		if i > 0 {
+			line(img, xOf(points[i-1].Time), yOf(points[i-1].Value), x, y, c)
+		}
+		if len(points) <= 60 {
+			fill(img, image.Rect(x-2, y-2, x+3, y+3), c)
+		}
+	}
+}
+
+// valueRange — границы шкалы, кратные «круглому» шагу делений; постоянное
+// значение рисуется посередине, неотрицательные счётчики — от нуля или выше
+func valueRange(points []Point) (lo, hi, step float64) {
+	lo, hi = math.Inf(1), math.Inf(-1)
+	for _, p := range points {
+		lo, hi = min(lo, p.Value), max(hi, p.Value)
+	}
+	if hi == lo {
+		d := math.Max(math.Abs(lo)*0.1, 1)
+		lo, hi = lo-d, hi+d
+		if lo < 0 && lo+d >= 0 {
+			lo, hi = 0, hi+d
+		}
+	}
+	step = niceStep((hi - lo) / yTicks)
+	return math.Floor(lo/step) * step, math.Ceil(hi/step) * step, step
+}
+
+// niceStep округляет шаг делений вверх до 1, 2, 2.5 или 5 × 10ⁿ
+func niceStep(v float64) float64 {
+	p := math.Pow(10, math.Floor(math.Log10(v)))
+	for _, m := range []float64{1, 2, 2.5, 5} {
+		if v <= m*p {
+			return m * p
+		}
+	}
+	return 10 * p
+}
+
+// FormatValue кратко записывает значение для подписи: 1.5K, 2.3T
+func FormatValue(v float64) string {
+	abs := math.Abs(v)
+	for _, u := range []struct {
+		div    float64
+		suffix string
+	}{{1e15, "P"}, {1e12, "T"}, {1e9, "G"}, {1e6, "M"}, {1e3, "K"}} {
+		if abs >= u.div {
+			return strconv.FormatFloat(round3(v/u.div), 'f', -1, 64) + u.suffix
+		}
+	}
+	return strconv.FormatFloat(round3(v), 'f', -1, 64)
+}
+
+// round3 округляет до трёх значащих цифр
+func round3(v float64) float64 {
+	if v == 0 {
+		return 0
+	}
+	p := math.Pow(10, 2-math.Floor(math.Log10(math.Abs(v))))
+	return math.Round(v*p) / p
+}
+
+func textWidth(s string) int {
+	n := 0
+	for range s {
+		n++
+	}
+	return n * (glyphW + 1) * scale
+}
+
+// text выводит строку растровым шрифтом, (x, y) — левый верхний угол
+func text(img *image.RGBA, x, y int, s string, c color.RGBA) {
+	for _, r := range s {
+		g := lookupGlyph(r)
+		for gy, row := range g {
+			for gx := 0; gx < glyphW; gx++ {
+				if row&(1<<(glyphW-1-gx)) != 0 {
+					fill(img, image.Rect(x+gx*scale, y+gy*scale, x+(gx+1)*scale, y+(gy+1)*scale), c)
+				}
+			}
+		}
+		x += (glyphW + 1) * scale
+	}
+}
+
+func fill(img *image.RGBA, r image.Rectangle, c color.RGBA) {
+	draw.Draw(img, r.Intersect(img.Bounds()), &image.Uniform{c}, image.Point{}, draw.Src)
+}
+
+func hline(img *image.RGBA, x1, x2, y int, c color.RGBA) {
+	fill(img, image.Rect(x1, y, x2+1, y+1), c)
+}
+
+func vline(img *image.RGBA, x, y1, y2 int, c color.RGBA) {
+	fill(img, image.Rect(x, y1, x+1, y2+1), c)
+}
+
+// line рисует отрезок толщиной 2 точки алгоритмом Брезенхэма
+func line(img *image.RGBA, x1, y1, x2, y2 int, c color.RGBA) {
+	dx, dy := abs(x2-x1), -abs(y2-y1)
+	sx, sy := sign(x2-x1), sign(y2-y1)
+	e := dx + dy
+	for {
+		fill(img, image.Rect(x1, y1, x1+2, y1+2), c)
+		if x1 == x2 && y1 == y2 {
+			return
+		}
+		e2 := 2 * e
+		if e2 >= dy {
+			e += dy
+			x1 += sx
+		}
+		if e2 <= dx {
+			e += dx
+			y1 += sy
+		}
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func sign(v int) int {
+	switch {
+	case v > 0:
+		return 1
+	case v < 0:
+		return -1
+	}
+	return 0
+}
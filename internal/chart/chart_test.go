@@ -0,0 +1,49 @@
+package chart
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+	"time"
+)
+
+func TestRender(t *testing.T) {
+	t0 := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	panels := []Panel{
+		{Title: "reallocated_sectors", Points: []Point{{t0, 0}, {t0.Add(24 * time.Hour), 8}, {t0.Add(48 * time.Hour), 24}}},
+		{Title: "temperature_celsius", Points: []Point{{t0, 35}}}, // одна точка и постоянное значение
+		{Title: "empty"},
+	}
+	data, err := Render("nas /dev/sda", time.Time{}, time.Time{}, panels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != width || b.Dy() != margin+titleHeight+2*panelHeight+margin {
+		t.Fatalf("bounds = %v", b)
+	}
+
+	if data, err := Render("x", time.Time{}, time.Time{}, []Panel{{Title: "empty"}}); data != nil || err != nil {
+		t.Fatalf("empty chart = %d bytes, %v", len(data), err)
+	}
+}
+
+func TestFormatValue(t *testing.T) {
+	for v, want := range map[float64]string{
+		0:       "0",
+		42:      "42",
+		1.25:    "1.25",
+		1500:    "1.5K",
+		2.5e12:  "2.5T",
+		123456:  "123K",
+		-3.5e6:  "-3.5M",
+		0.12345: "0.123",
+	} {
+		if got := FormatValue(v); got != want {
+			t.Errorf("FormatValue(%v) = %q, want %q", v, got, want)
+		}
+	}
+}
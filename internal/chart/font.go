@@ -0,0 +1,88 @@
+package chart
+
+import "unicode"
+
+// Растровый шрифт 5×7 для подписей: цифры, латиница (строчные выводятся
+// заглавными) и знаки, встречающиеся в именах хостов, устройств и значениях.
+// Остальные символы выводятся как «?».
+const (
+	glyphW = 5
+	glyphH = 7
+)
+
+var glyphSrc = map[rune][glyphH]string{
+	'0': {" ### ", "#   #", "#  ##", "# # #", "##  #", "#   #", " ### "},
+	'1': {"  #  ", " ##  ", "  #  ", "  #  ", "  #  ", "  #  ", " ### "},
+	'2': {" ### ", "#   #", "    #", "   # ", "  #  ", " #   ", "#####"},
+	'3': {"#####", "   # ", "  #  ", "   # ", "    #", "#   #", " ### "},
+	'4': {"   # ", "  ## ", " # # ", "#  # ", "#####", "   # ", "   # "},
+	'5': {"#####", "#    ", "#### ", "    #", "    #", "#   #", " ### "},
+	'6': {"  ## ", " #   ", "#    ", "#### ", "#   #", "#   #", " ### "},
+	'7': {"#####", "    #", "   # ", "  #  ", " #   ", " #   ", " #   "},
+	'8': {" ### ", "#   #", "#   #", " ### ", "#   #", "#   #", " ### "},
+	'9': {" ### ", "#   #", "#   #", " ####", "    #", "   # ", " ##  "},
+	'A': {" ### ", "#   #", "#   #", "#####", "#   #", "#   #", "#   #"},
+	'B': {"#### ", "#   #", "#   #", "#### ", "#   #", "#   #", "#### "},
+	'C': {" ### ", "#   #", "#    ", "#    ", "#    ", "#   #", " ### "},
+	'D': {"###  ", "#  # ", "#   #", "#   #", "#   #", "#  # ", "###  "},
+	'E': {"#####", "#    ", "#    ", "#### ", "#    ", "#    ", "#####"},
+	'F': {"#####", "#    ", "#    ", "#### ", "#    ", "#    ", "#    "},
+	'G': {" ### ", "#   #", "#    ", "# ###", "#   #", "#   #", " ####"},
+	'H': {"#   #", "#   #", "#   #", "#####", "#   #", "#   #", "#   #"},
+	'I': {" ### ", "  #  ", "  #  ", "  #  ", "  #  ", "  #  ", " ### "},
+	'J': {"  ###", "   # ", "   # ", "   # ", "   # ", "#  # ", " ##  "},
+	'K': {"#   #", "#  # ", "# #  ", "##   ", "# #  ", "#  # ", "#   #"},
+	'L': {"#    ", "#    ", "#    ", "#    ", "#    ", "#    ", "#####"},
+	'M': {"#   #", "## ##", "# # #", "# # #", "#   #", "#   #", "#   #"},
+	'N': {"#   #", "#   #", "##  #", "# # #", "#  ##", "#   #", "#   #"},
+	'O': {" ### ", "#   #", "#   #", "#   #", "#   #", "#   #", " ### "},
+	'P': {"#### ", "#   #", "#   #", "#### ", "#    ", "#    ", "#    "},
+	'Q': {" ### ", "#   #", "#   #", "#   #", "# # #", "#  # ", " ## #"},
+	'R': {"#### ", "#   #", "#   #", "#### ", "# #  ", "#  # ", "#   #"},
+	'S': {" ####", "#    ", "#    ", " ### ", "    #", "    #", "#### "},
+	'T': {"#####", "  #  ", "  #  ", "  #  ", "  #  ", "  #  ", "  #  "},
+	'U': {"#   #", "#   #", "#   #", "#   #", "#   #", "#   #", " ### "},
+	'V': {"#   #", "#   #", "#   #", "#   #", "#   #", " # # ", "  #  "},
+	'W': {"#   #", "#   #", "#   #", "# # #", "# # #", "# # #", " # # "},
+	'X': {"#   #", "#   #", " # # ", "  #  ", " # # ", "#   #", "#   #"},
+	'Y': {"#   #", "#   #", " # # ", "  #  ", "  #  ", "  #  ", "  #  "},
+	'Z': {"#####", "    #", "   # ", "  #  ", " #   ", "#    ", "#####"},
+	' ': {"     ", "     ", "     ", "     ", "     ", "     ", "     "},
+	'.': {"     ", "     ", "     ", "     ", "     ", " ##  ", " ##  "},
+	',': {"     ", "     ", "     ", "     ", " ##  ", "  #  ", " #   "},
+	'-': {"     ", "     ", "     ", "#####", "     ", "     ", "     "},
+	'+': {"     ", "  #  ", "  #  ", "#####", "  #  ", "  #  ", "     "},
+	'_': {"     ", "     ", "     ", "     ", "     ", "     ", "#####"},
+	':': {"     ", " ##  ", " ##  ", "     ", " ##  ", " ##  ", "     "},
+	'/': {"     ", "    #", "   # ", "  #  ", " #   ", "#    ", "     "},
+	'%': {"##   ", "##  #", "   # ", "  #  ", " #   ", "#  ##", "   ##"},
+	'(': {"   # ", "  #  ", " #   ", " #   ", " #   ", "  #  ", "   # "},
+	')': {" #   ", "  #  ", "   # ", "   # ", "   # ", "  #  ", " #   "},
+	'?': {" ### ", "#   #", "    #", "   # ", "  #  ", "     ", "  #  "},
+}
+
+// glyph — строки растра символа, по биту на точку (старший из пяти — левый)
+type glyph [glyphH]uint8
+
+var glyphs = func() map[rune]glyph {
+	ret := make(map[rune]glyph, len(glyphSrc))
+	for r, src := range glyphSrc {
+		var g glyph
+		for y, row := range src {
+			for x, c := range row {
+				if c == '#' {
+					g[y] |= 1 << (glyphW - 1 - x)
+				}
+			}
+		}
+		ret[r] = g
+	}
+	return ret
+}()
+
+func lookupGlyph(r rune) glyph {
+	if g, ok := glyphs[unicode.ToUpper(r)]; ok {
+		return g
+	}
+	return glyphs['?']
+}
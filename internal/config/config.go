@@ -0,0 +1,143 @@
+// Package config загружает файл настроек сервера. Простые параметры задаются
+// переменными окружения, а структурированные (правила маршрутизации и т.п.) —
+// в JSON-файле.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/covrom/smart-control/internal/llmdesc"
+	"github.com/covrom/smart-control/internal/notify"
+	"github.com/covrom/smart-control/internal/route"
+)
+
+// Config — настройки сервера из файла
+type Config struct {
+	Routes   []route.Route          `json:"routes,omitempty"`
+	Email    []notify.EmailConfig   `json:"email,omitempty"`    // почтовые каналы
+	Webhooks []notify.WebhookConfig `json:"webhooks,omitempty"` // HTTP-запросы по шаблону
+	Matrix   []notify.MatrixConfig  `json:"matrix,omitempty"`   // комнаты Matrix
+	Push     []notify.PushConfig    `json:"push,omitempty"`     // темы ntfy и приложения Gotify
+	// LLM — поставщики LLM в порядке обращения; пустой список — один
+	// поставщик из переменных окружения OPENAI_*
+	LLM []llmdesc.ProviderConfig `json:"llm,omitempty"`
+}
+
+// Load читает настройки из файла filename. Отсутствующий файл — пустые
+// настройки; неизвестные поля и неверные правила — ошибка.
+func Load(filename string) (Config, error) {
+	var cfg Config
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return cfg, fmt.Errorf("parse %s: %w", filename, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return cfg, fmt.Errorf("%s: %w", filename, err)
+	}
+	return cfg, nil
+}
+
+// Validate проверяет настройки: правила могут ссылаться только на описанные
+// каналы, имена каналов и поставщиков LLM уникальны
+func (c Config) Validate() error {
+	names := make(map[string]bool)
+	addName := func(name string) error {
+		if name == "" {
+			return errors.New("notifier without name")
+		}
+		if names[name] {
+			return fmt.Errorf("duplicate notifier name %q", name)
+		}
+		names[name] = true
+		return nil
+	}
+	for _, e := range c.Email {
+		if err := addName(e.Name); err != nil {
+			return err
+		}
+		if err := e.Validate(); err != nil {
+			return err
+		}
+	}
+	for _, w := range c.Webhooks {
+		if err := addName(w.Name); err != nil {
+			return err
+		}
+		if err := w.Validate(); err != nil {
+			return err
+		}
+	}
+	for _, m := range c.Matrix {
+		if err := addName(m.Name); err != nil {
+			return err
+		}
+		if err := m.Validate(); err != nil {
+			return err
+		}
+	}
+	for _, p := range c.Push {
+		if err := addName(p.Name); err != nil {
+			return err
+		}
+		if err := p.Validate(); err != nil {
+			return err
+		}
+	}
+
+	providers := make(map[string]bool)
+	for _, p := range c.LLM {
+		if p.Name == "" {
+			return errors.New("llm provider without name")
+		}
+		if providers[p.Name] {
+			return fmt.Errorf("duplicate llm provider name %q", p.Name)
+		}
+		providers[p.Name] = true
+		if err := p.Validate(); err != nil {
+			return err
+		}
+	}
+
+	for _, r := range c.Routes {
+		if err := r.Validate(); err != nil {
+			return err
+		}
+		for _, n := range r.Notify {
+			if !names[n] {
+				return fmt.Errorf("route %q: unknown notifier %q", r.Name, n)
+			}
+		}
+	}
+	return nil
+}
+
+// Notifiers создаёт описанные в настройках каналы доставки по именам
+func (c Config) Notifiers() map[string]notify.Notifier {
+	ret := make(map[string]notify.Notifier)
+	for _, e := range c.Email {
+		ret[e.Name] = notify.NewEmail(e)
+	}
+	for _, w := range c.Webhooks {
+		ret[w.Name] = notify.NewWebhook(w)
+	}
+	for _, m := range c.Matrix {
+		ret[m.Name] = notify.NewMatrix(m)
+	}
+	for _, p := range c.Push {
+		ret[p.Name] = notify.NewPush(p)
+	}
+	return ret
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/covrom/smart-control/internal/smartdata"
+	"github.com/covrom/smart-control/internal/version"
 )
 
 // getSmartDevices вызывает smartctl --scan-open и возвращает список устройств
@@ -98,11 +99,13 @@ func SmartReportOnAllDevices(ctx context.Context, hostname string) smartdata.Com
 		Hostname:  hostname,
 		OS:        "linux",
 		Timestamp: time.Now(),
+
+		AgentVersion: version.Version,
 	}
 
 	devices, err := getSmartDevices()
 	if err != nil {
-		report.RawError = fmt.Sprintf("Ошибка получения списка дисков: %s", err.Error())
+		report.ErrorCode, report.RawError = smartdata.ErrCodeListDevices, err.Error()
 		slog.Error("getSmartDevices error", "err", err)
 		return report
 	}
@@ -112,7 +115,8 @@ func SmartReportOnAllDevices(ctx context.Context, hostname string) smartdata.Com
 			result, err := runSmartctlCommands(device)
 			if err != nil {
 				slog.Error("smartctl error", "device", device, "err", err)
-				device.RawError = fmt.Sprintf("Ошибка анализа %s(%s):\n%s\n%s", device.Device, device.Type, err.Error(), result)
+				device.ErrorCode = smartdata.ErrCodeSmartctl
+				device.RawError = fmt.Sprintf("%s (%s): %s\n%s", device.Device, device.Type, err.Error(), result)
 			} else {
 				slog.Info("smartctl analysis done", "device", device)
 				device.SMARTData = result
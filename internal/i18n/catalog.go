@@ -0,0 +1,134 @@
+package i18n
+
+// catalog — тексты по ключам на всех языках. Тексты с разметкой — форматы
+// tghtml.Sprintf, остальные — fmt.Sprintf.
+var catalog = map[string]map[Lang]string{
+	// уровни состояния
+	"level.ok":       {EN: "ok", RU: "норма"},
+	"level.warning":  {EN: "warning", RU: "предупреждение"},
+	"level.critical": {EN: "critical", RU: "критично"},
+	"level.unknown":  {EN: "unknown", RU: "неизвестно"},
+
+	// ошибки агентов по кодам smartdata.ErrCode*
+	"error.list_devices": {EN: "Failed to list disks", RU: "Ошибка получения списка дисков"},
+	"error.smartctl":     {EN: "smartctl failed to read the disk", RU: "Ошибка анализа диска smartctl"},
+	"error.analysis":     {EN: "Analysis unavailable: no LLM provider answered", RU: "Анализ недоступен: ни один поставщик LLM не ответил"},
+
+	// кнопки уведомлений и отметки о нажатии
+	"button.ack":       {EN: "✔️ Acknowledge", RU: "✔️ Принято"},
+	"button.snooze":    {EN: "💤 Snooze for 7 days", RU: "💤 Отложить на 7 дней"},
+	"button.mute":      {EN: "🔕 Mute", RU: "🔕 Отключить"},
+	"button.unmute":    {EN: "🔔 Unmute", RU: "🔔 Включить"},
+	"button.raw":       {EN: "📄 Raw data", RU: "📄 Сырые данные"},
+	"button.acked":     {EN: "✔️ Acknowledged by %s", RU: "✔️ Принято: %s"},
+	"button.snoozed":   {EN: "💤 Snoozed until %s by %s", RU: "💤 Отложено до %s: %s"},
+	"button.muted":     {EN: "🔕 Alerts muted by %s", RU: "🔕 Оповещения отключены: %s"},
+	"button.unmuted":   {EN: "🔔 Alerts unmuted by %s", RU: "🔔 Оповещения включены: %s"},
+	"device.not_found": {EN: "Device not found", RU: "Устройство не найдено"},
+	"chat.not_allowed": {EN: "This chat is not allowed to control alerts", RU: "В этом чате управление оповещениями недоступно"},
+	"error":            {EN: "❌ Error: %s", RU: "❌ Ошибка: %s"},
+
+	// команды бота
+	"cmd.help":         {EN: "Commands:", RU: "Команды:"},
+	"cmd.status":       {EN: "summary of all hosts", RU: "сводка по всем хостам"},
+	"cmd.hosts":        {EN: "list of hosts", RU: "список хостов"},
+	"cmd.host":         {EN: "disks of a host", RU: "диски хоста"},
+	"cmd.host.args":    {EN: "<host>", RU: "<хост>"},
+	"cmd.device":       {EN: "disk state", RU: "состояние диска"},
+	"cmd.device.args":  {EN: "[host] <disk>", RU: "[хост] <диск>"},
+	"cmd.history":      {EN: "disk metrics history", RU: "история показателей диска"},
+	"cmd.history.args": {EN: "[host] <disk> [30d]", RU: "[хост] <диск> [30d]"},
+	"cmd.raw":          {EN: "smartctl output and diff with the previous snapshot", RU: "вывод smartctl и сравнение с предыдущим снимком"},
+	"cmd.analyze":      {EN: "re-run the analysis of the latest snapshot", RU: "повторить анализ последнего снимка"},
+
+	"hosts.none":         {EN: "No hosts yet", RU: "Хостов пока нет"},
+	"hosts.line":         {EN: "%s <b>%s</b> (%s), disks: %d, report %s", RU: "%s <b>%s</b> (%s), дисков: %d, отчёт %s"},
+	"host.usage":         {EN: "Usage: /host <host>", RU: "Использование: /host <хост>"},
+	"host.not_found":     {EN: "Host not found: %s", RU: "Хост не найден: %s"},
+	"host.header":        {EN: "🖥 <b>%s</b> (%s)\nAgent: %s\nLast report: %s\n", RU: "🖥 <b>%s</b> (%s)\nАгент: %s\nПоследний отчёт: %s\n"},
+	"host.missing":       {EN: "⏰ No report since %s\n", RU: "⏰ Нет отчёта с %s\n"},
+	"device.no_snapshot": {EN: "No snapshots of this device yet", RU: "Снимков устройства ещё нет"},
+	"device.usage":       {EN: "Specify a disk: [host] <disk>, e.g. sda or nvme0", RU: "Укажите диск: [хост] <диск>, например sda или nvme0"},
+	"device.unknown":     {EN: "Disk not found: %s", RU: "Диск не найден: %s"},
+	"device.ambiguous":   {EN: "Disk %s exists on several hosts (%s), specify the host", RU: "Диск %s есть на нескольких хостах (%s), укажите хост"},
+	"period.invalid":     {EN: "Invalid period: %s", RU: "Неверный период: %s"},
+
+	"history.empty":     {EN: "No snapshots in this period", RU: "За этот период снимков нет"},
+	"history.header":    {EN: "📈 <b>%s %s</b> for %s, snapshots: %d\n\n", RU: "📈 <b>%s %s</b> за %s, снимков: %d\n\n"},
+	"history.truncated": {EN: "(last %d shown)\n", RU: "(показаны последние %d)\n"},
+	"history.changes":   {EN: "\n<b>Changes over the period:</b> %s", RU: "\n<b>Изменения за период:</b> %s"},
+	"history.unchanged": {EN: "\nError and wear counters did not change over the period", RU: "\nСчётчики ошибок и износа за период не изменились"},
+
+	"analyze.no_data": {EN: "No smartctl data to analyze", RU: "Нет данных smartctl для анализа"},
+	"analyze.running": {EN: "⏳ Analyzing…", RU: "⏳ Анализирую…"},
+	"analyze.failed":  {EN: "❌ Failed to get an analysis from the LLM", RU: "❌ Не удалось получить анализ от LLM"},
+
+	"snapshot.model":    {EN: "Model: <code>%s</code>\n", RU: "Модель: <code>%s</code>\n"},
+	"snapshot.firmware": {EN: "Firmware: %s\n", RU: "Прошивка: %s\n"},
+	"snapshot.mounts":   {EN: "Mount points: %s\n", RU: "Точки монтирования: %s\n"},
+	"snapshot.time":     {EN: "Snapshot: %s\n", RU: "Снимок: %s\n"},
+
+	"raw.no_data":   {EN: "No smartctl data", RU: "Нет данных smartctl"},
+	"raw.no_prev":   {EN: "No previous snapshot to compare with", RU: "Предыдущего снимка нет, сравнивать не с чем"},
+	"raw.unchanged": {EN: "smartctl output has not changed since the previous snapshot", RU: "Вывод smartctl не изменился с предыдущего снимка"},
+	"raw.diff":      {EN: "Diff %s %s: %s → %s", RU: "Сравнение %s %s: %s → %s"},
+
+	// недоставленные сообщения
+	"dead.notice": {
+		EN: "⚠️ <b>Message not delivered</b> %s after %d attempts\nError: <code>%s</code>\nJob: <code>%s</code>\n%s",
+		RU: "⚠️ <b>Сообщение не доставлено</b> %s после %d попыток\nОшибка: <code>%s</code>\nЗадача: <code>%s</code>\n%s",
+	},
+	"dead.channel": {EN: "to channel <code>%s</code>", RU: "в канал <code>%s</code>"},
+	"dead.chat":    {EN: "to chat <code>%d</code>", RU: "в чат <code>%d</code>"},
+
+	// сведения о диске в заключении LLM
+	"verdict.capacity":       {EN: "Capacity: ", RU: "Ёмкость: "},
+	"verdict.endurance":      {EN: "Endurance: ", RU: "Ресурс: "},
+	"verdict.power_on_hours": {EN: "Power-on hours: ", RU: "Наработка (ч): "},
+	"verdict.total_written":  {EN: "Total written: ", RU: "Записано: "},
+
+	// таблица атрибутов SMART
+	"table.id":           {EN: "ID", RU: "ID"},
+	"table.attribute":    {EN: "Attribute", RU: "Атрибут"},
+	"table.value":        {EN: "Value", RU: "Знач"},
+	"table.worst":        {EN: "Worst", RU: "Худш"},
+	"table.thresh":       {EN: "Thresh", RU: "Порог"},
+	"table.raw":          {EN: "Raw", RU: "Raw"},
+	"table.metric":       {EN: "Metric", RU: "Показатель"},
+	"table.metric_value": {EN: "Value", RU: "Значение"},
+
+	// веб-интерфейс
+	"web.disks":           {EN: "Disks", RU: "Диски"},
+	"web.agent":           {EN: "agent %s", RU: "агент %s"},
+	"web.report":          {EN: "report %s", RU: "отчёт %s"},
+	"web.missing":         {EN: "⏰ No report, expected %s", RU: "⏰ Нет отчёта, ожидался %s"},
+	"web.no_reports":      {EN: "No reports from agents yet.", RU: "Отчётов от агентов пока нет."},
+	"web.device":          {EN: "Device", RU: "Устройство"},
+	"web.model":           {EN: "Model", RU: "Модель"},
+	"web.serial":          {EN: "Serial number", RU: "Серийный номер"},
+	"web.type":            {EN: "Type", RU: "Тип"},
+	"web.mount_paths":     {EN: "Mount points", RU: "Точки монтирования"},
+	"web.last_snapshot":   {EN: "Last snapshot", RU: "Последний снимок"},
+	"web.verdict":         {EN: "Verdict", RU: "Заключение"},
+	"web.snapshot":        {EN: "Snapshot", RU: "Снимок"},
+	"web.temperature":     {EN: "Temp., °C", RU: "Темп., °C"},
+	"web.reallocated":     {EN: "Realloc. sectors", RU: "Перенос. секторы"},
+	"web.pending":         {EN: "Pend. sectors", RU: "Ожид. секторы"},
+	"web.analyses":        {EN: "Analyses", RU: "Анализы"},
+	"web.reused":          {EN: "verdict repeated from %s", RU: "повтор заключения от %s"},
+	"web.no_snapshots":    {EN: "No snapshots.", RU: "Снимков нет."},
+	"web.smartctl_output": {EN: "smartctl output", RU: "Вывод smartctl"},
+	"web.age.now":         {EN: "just now", RU: "только что"},
+	"web.age.minutes":     {EN: "%d min ago", RU: "%d мин назад"},
+	"web.age.hours":       {EN: "%d h ago", RU: "%d ч назад"},
+	"web.age.days":        {EN: "%d d ago", RU: "%d дн назад"},
+
+	// показатели на странице устройства и подписи графиков
+	"web.metric.temperature_celsius": {EN: "Temperature, °C", RU: "Температура, °C"},
+	"web.metric.power_on_hours":      {EN: "Power-on hours", RU: "Наработка, ч"},
+	"web.metric.percentage_used":     {EN: "Wear, %", RU: "Износ, %"},
+	"web.metric.available_spare":     {EN: "Spare, %", RU: "Резерв, %"},
+	"web.metric.bytes_written":       {EN: "Written", RU: "Записано"},
+	"web.metric.reallocated_sectors": {EN: "Reallocated sectors", RU: "Переназначенные секторы"},
+	"web.metric.pending_sectors":     {EN: "Pending sectors", RU: "Ожидающие секторы"},
+}
@@ -0,0 +1,96 @@
+// Package i18n — языки сообщений сервера: каталог переводов текстов бота
+// и уведомлений, форматы дат и названия языков для запросов к LLM.
+package i18n
+
+import (
+	"fmt"
+	"time"
+)
+
+// Lang — язык сообщений
+type Lang string
+
+const (
+	EN Lang = "en"
+	RU Lang = "ru"
+)
+
+// Langs — поддерживаемые языки
+var Langs = []Lang{EN, RU}
+
+// Default — язык сервера, если он не задан
+const Default = RU
+
+// Parse проверяет код языка
+func Parse(s string) (Lang, error) {
+	for _, l := range Langs {
+		if string(l) == s {
+			return l, nil
+		}
+	}
+	return "", fmt.Errorf("unknown language %q (supported: en, ru)", s)
+}
+
+// Or возвращает l или def, если язык не задан
+func (l Lang) Or(def Lang) Lang {
+	if l == "" {
+		return def
+	}
+	return l
+}
+
+// Name — название языка по-английски, для указаний LLM
+func (l Lang) Name() string {
+	switch l {
+	case EN:
+		return "English"
+	case RU:
+		return "Russian"
+	}
+	return string(l)
+}
+
+// T возвращает текст key на языке l; при отсутствии перевода — английский
+// текст, а если нет и его — сам ключ
+func (l Lang) T(key string) string {
+	tr, ok := catalog[key]
+	if !ok {
+		return key
+	}
+	if s, ok := tr[l]; ok {
+		return s
+	}
+	if s, ok := tr[EN]; ok {
+		return s
+	}
+	return key
+}
+
+// Sprintf форматирует текст key на языке l
+func (l Lang) Sprintf(key string, args ...any) string {
+	return fmt.Sprintf(l.T(key), args...)
+}
+
+// DateTime — дата и время в принятом для языка виде
+func (l Lang) DateTime(t time.Time) string {
+	if l == EN {
+		return t.Local().Format("2006-01-02 15:04")
+	}
+	return t.Local().Format("02.01.2006 15:04")
+}
+
+// Date — дата в принятом для языка виде
+func (l Lang) Date(t time.Time) string {
+	if l == EN {
+		return t.Local().Format("2006-01-02")
+	}
+	return t.Local().Format("02.01.2006")
+}
+
+// ShortDateTime — день, месяц и время без года, для списков
+func (l Lang) ShortDateTime(t time.Time) string {
+	if l == EN {
+		return t.Local().Format("01-02 15:04")
+	}
+	return t.Local().Format("02.01 15:04")
+}
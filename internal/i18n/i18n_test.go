@@ -0,0 +1,28 @@
+package i18n
+
+import "testing"
+
+func TestCatalogComplete(t *testing.T) {
+	for key, tr := range catalog {
+		for _, l := range Langs {
+			if tr[l] == "" {
+				t.Errorf("%s: no %s translation", key, l)
+			}
+		}
+	}
+}
+
+func TestLang(t *testing.T) {
+	if got := EN.Sprintf("host.not_found", "nas"); got != "Host not found: nas" {
+		t.Errorf("got %q", got)
+	}
+	if got := RU.T("no.such.key"); got != "no.such.key" {
+		t.Errorf("got %q", got)
+	}
+	if _, err := Parse("de"); err == nil {
+		t.Error("expected error for unsupported language")
+	}
+	if Lang("").Or(RU) != RU || EN.Or(RU) != EN {
+		t.Error("Or")
+	}
+}
@@ -0,0 +1,276 @@
+package llmdesc
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/covrom/smart-control/internal/smartdata"
+)
+
+// Режимы входных данных LLM (ProviderConfig.Input)
+const (
+	// InputCompact — выжимка из разобранных показателей с изменениями
+	// относительно предыдущего снимка и последними записями журналов
+	InputCompact = "compact"
+	// InputRaw — полный вывод smartctl -a текущего и предыдущего снимков
+	InputRaw = "raw"
+)
+
+// defaultInputTokens — бюджет выжимки по умолчанию, токенов: вместе
+// с системным запросом и ответом она помещается в контекст 4096 токенов
+const defaultInputTokens = 2000
+
+// maxLogEntries — сколько последних записей журналов ошибок и самопроверок
+// передавать в выжимке
+const maxLogEntries = 5
+
+// compactMetrics — показатели выжимки в порядке вывода; объёмы — в терабайтах
+var compactMetrics = []string{
+	smartdata.MetricTemperature,
+	smartdata.MetricPowerOnHours,
+	smartdata.MetricPowerCycles,
+	smartdata.MetricReallocated,
+	smartdata.MetricPending,
+	smartdata.MetricOfflineUncorr,
+	smartdata.MetricCRCErrors,
+	smartdata.MetricPercentageUsed,
+	smartdata.MetricAvailableSpare,
+	smartdata.MetricMediaErrors,
+	smartdata.MetricErrorLogEntries,
+	smartdata.MetricUnsafeShutdowns,
+	smartdata.MetricCriticalWarning,
+	smartdata.MetricGrownDefects,
+	smartdata.MetricBytesWritten,
+	smartdata.MetricBytesRead,
+}
+
+// relevantAttributes — атрибуты ATA, значимые для оценки состояния. Прочие
+// атрибуты попадают в выжимку, только если достигли порога или близки к нему.
+var relevantAttributes = []int{
+	1, 5, 7, 9, 10, 12, 177, 179, 181, 182, 183, 184, 187, 188,
+	190, 194, 196, 197, 198, 199, 202, 231, 232, 233, 241,
+}
+
+// nearThreshold — запас нормализованного значения до порога, при котором
+// атрибут считается близким к отказу
+const nearThreshold = 10
+
+// estimateTokens грубо оценивает число токенов текста: для английского
+// текста и чисел — около четырёх байт на токен
+func estimateTokens(s string) int {
+	return (len(s) + 3) / 4
+}
+
+// compactInput составляет выжимку по снимку dev и предыдущему снимку prev
+// в пределах budget токенов. Сведения о диске и показатели передаются всегда;
+// атрибуты, журнал ошибок и журнал самопроверок добавляются в этом порядке
+// построчно, пока хватает бюджета, а о пропущенных строках сообщается
+// отдельной строкой. Строки журналов идут от самых свежих.
+func compactInput(dev, prev smartdata.SMARTDevice, budget int) string {
+	cur := smartdata.ParseSmartctl(dev.SMARTData)
+	var old smartdata.Metrics
+	if prev.SMARTData != "" {
+		old = smartdata.ParseSmartctl(prev.SMARTData)
+	}
+
+	var b strings.Builder
+	b.WriteString(strings.Join(identity(dev.Device, cur), "\n"))
+	b.WriteString("\n\nMetrics:\n")
+	b.WriteString(strings.Join(metricLines(cur, old), "\n"))
+	used := estimateTokens(b.String())
+
+	errHeader, errRows := errorLog(dev.SMARTData)
+	testHeader, testRows := logTable(dev.SMARTData, isSelfTestHeader)
+	for _, s := range []struct {
+		title string
+		lines []string
+	}{
+		{"ATA attributes (ID name value/worst/thresh raw):", attributeLines(cur, old)},
+		{tableTitle("Recent error log entries", errHeader), errRows},
+		{tableTitle("Recent self-tests", testHeader), testRows},
+	} {
+		title := "\n\n" + s.title
+		cost := used + estimateTokens(title)
+		var kept []string
+		for _, line := range s.lines {
+			c := estimateTokens("\n" + line)
+			if cost+c > budget {
+				break
+			}
+			cost += c
+			kept = append(kept, line)
+		}
+		if len(s.lines) == 0 {
+			continue
+		}
+		// о пропущенной части модель должна знать: отсутствие записей
+		// журнала иначе читается как их отсутствие на диске
+		note := fmt.Sprintf("\n(%d more lines omitted)", len(s.lines)-len(kept))
+		if len(kept) == 0 {
+			note = fmt.Sprintf("\n(%d lines omitted)", len(s.lines))
+		}
+		b.WriteString(title)
+		for _, line := range kept {
+			b.WriteString("\n" + line)
+		}
+		if len(kept) < len(s.lines) {
+			b.WriteString(note)
+			cost += estimateTokens(note)
+		}
+		used = cost
+	}
+	return b.String()
+}
+
+// identity — сведения о диске
+func identity(device string, m smartdata.Metrics) []string {
+	ret := []string{"Device: " + device}
+	add := func(label, value string) {
+		if value != "" {
+			ret = append(ret, label+": "+value)
+		}
+	}
+	add("Model family", m.ModelFamily)
+	add("Model", m.Model)
+	add("Serial", m.Serial)
+	add("Firmware", m.Firmware)
+	if v, ok := m.Value(smartdata.MetricCapacityBytes); ok {
+		add("Capacity", terabytes(v))
+	}
+	add("SMART overall health", m.Health)
+	return ret
+}
+
+// metricLines — показатели с изменением относительно предыдущего снимка
+// в скобках
+func metricLines(cur, old smartdata.Metrics) []string {
+	var ret []string
+	for _, name := range compactMetrics {
+		v, ok := cur.Value(name)
+		if !ok {
+			continue
+		}
+		line := name + ": " + metricValue(name, v)
+		if p, ok := old.Value(name); ok && p != v {
+			sign := "+"
+			if v < p {
+				sign = "-"
+			}
+			d := v - p
+			if d < 0 {
+				d = -d
+			}
+			line += " (" + sign + metricValue(name, d) + ")"
+		}
+		ret = append(ret, line)
+	}
+	return ret
+}
+
+func metricValue(name string, v float64) string {
+	if name == smartdata.MetricBytesWritten || name == smartdata.MetricBytesRead {
+		return terabytes(v)
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func terabytes(v float64) string {
+	return fmt.Sprintf("%.2f TB", v/1e12)
+}
+
+// attributeLines — значимые атрибуты ATA, а также достигшие порога или
+// близкие к нему; прежнее сырое значение выводится, если оно изменилось
+func attributeLines(cur, old smartdata.Metrics) []string {
+	var ret []string
+	for _, a := range cur.Attributes {
+		near := a.Thresh > 0 && a.Value-a.Thresh <= nearThreshold
+		if !slices.Contains(relevantAttributes, a.ID) && a.WhenFailed == "" && !near {
+			continue
+		}
+		line := fmt.Sprintf("%d %s %d/%d/%d raw %s", a.ID, a.Name, a.Value, a.Worst, a.Thresh, a.Raw)
+		if i := slices.IndexFunc(old.Attributes, func(o smartdata.Attribute) bool { return o.ID == a.ID }); i >= 0 && old.Attributes[i].Raw != a.Raw {
+			line += " (was " + old.Attributes[i].Raw + ")"
+		}
+		if a.WhenFailed != "" {
+			line += " WHEN_FAILED " + a.WhenFailed
+		}
+		ret = append(ret, line)
+	}
+	return ret
+}
+
+// errorLog — последние записи журнала ошибок: у ATA — строки «Error N
+// occurred at ...» с описанием ошибки из строки регистров, у NVMe — строки
+// таблицы Error Information с заголовком
+func errorLog(text string) (header string, rows []string) {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		t := strings.TrimSpace(line)
+		if !strings.HasPrefix(t, "Error ") || !strings.Contains(t, " occurred at ") {
+			continue
+		}
+		entry := compactSpaces(t)
+		for _, next := range lines[i+1:] {
+			n := strings.TrimSpace(next)
+			if strings.HasPrefix(n, "Error ") && strings.Contains(n, " occurred at ") {
+				break
+			}
+			if _, desc, ok := strings.Cut(n, "Error: "); ok {
+				entry += " - " + compactSpaces(desc)
+				break
+			}
+		}
+		rows = append(rows, entry)
+		if len(rows) == maxLogEntries {
+			break
+		}
+	}
+	if len(rows) > 0 {
+		return "", rows
+	}
+	return logTable(text, func(h string) bool { return strings.Contains(h, "ErrCount") })
+}
+
+func isSelfTestHeader(h string) bool {
+	return strings.Contains(h, "Test")
+}
+
+// logTable находит таблицу журнала с заголовком «Num ...», подходящим под
+// match, и возвращает заголовок и до maxLogEntries первых (самых свежих)
+// строк. Строки таблицы начинаются с «#» или номера.
+func logTable(text string, match func(header string) bool) (header string, rows []string) {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		t := strings.TrimSpace(line)
+		if !strings.HasPrefix(t, "Num ") || !match(t) {
+			continue
+		}
+		for _, row := range lines[i+1:] {
+			r := strings.TrimSpace(row)
+			if r == "" || len(rows) == maxLogEntries {
+				break
+			}
+			if r[0] == '#' || (r[0] >= '0' && r[0] <= '9') {
+				rows = append(rows, compactSpaces(r))
+			}
+		}
+		return compactSpaces(t), rows
+	}
+	return "", nil
+}
+
+// tableTitle — заголовок части выжимки со столбцами таблицы журнала
+func tableTitle(title, header string) string {
+	if header == "" {
+		return title + ":"
+	}
+	return title + " (" + header + "):"
+}
+
+// compactSpaces заменяет выравнивание таблиц одиночными пробелами: для
+// модели оно бесполезно, а токены расходует
+func compactSpaces(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,105 @@
+package llmdesc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/covrom/smart-control/internal/i18n"
+	"github.com/covrom/smart-control/internal/smartdata"
+)
+
+const ataOutput = `=== START OF INFORMATION SECTION ===
+Model Family:     Western Digital Red
+Device Model:     WDC WD40EFRX-68N32N0
+Serial Number:    WD-WCC7K0123456
+Firmware Version: 82.00A82
+User Capacity:    4,000,787,030,016 bytes [4.00 TB]
+
+=== START OF READ SMART DATA SECTION ===
+SMART overall-health self-assessment test result: PASSED
+
+ID# ATTRIBUTE_NAME          FLAG     VALUE WORST THRESH TYPE      UPDATED  WHEN_FAILED RAW_VALUE
+  1 Raw_Read_Error_Rate     0x002f   200   200   051    Pre-fail  Always       -       0
+  3 Spin_Up_Time            0x0027   176   172   021    Pre-fail  Always       -       8175
+  5 Reallocated_Sector_Ct   0x0033   196   196   140    Pre-fail  Always       -       24
+  9 Power_On_Hours          0x0032   058   058   000    Old_age   Always       -       31048
+ 11 Calibration_Retry_Count 0x0032   100   253   000    Old_age   Always       -       0
+197 Current_Pending_Sector  0x0032   200   200   000    Old_age   Always       -       2
+
+SMART Error Log Version: 1
+ATA Error Count: 7 (device log contains only the most recent five errors)
+Error 7 occurred at disk power-on lifetime: 31040 hours (1293 days + 8 hours)
+  When the command that caused the error occurred, the device was active or idle.
+
+  After command completion occurred, registers were:
+  ER ST SC SN CL CH DH
+  -- -- -- -- -- -- --
+  40 51 08 56 34 12 e0  Error: UNC 8 sectors at LBA = 0x00123456 = 1193046
+
+Error 6 occurred at disk power-on lifetime: 31039 hours (1293 days + 7 hours)
+  40 51 08 56 34 12 e0  Error: UNC 8 sectors at LBA = 0x00123456 = 1193046
+
+SMART Self-test log structure revision number 1
+Num  Test_Description    Status                  Remaining  LifeTime(hours)  LBA_of_first_error
+# 1  Short offline       Completed: read failure       90%     31045         1193046
+# 2  Short offline       Completed without error       00%     30877         -
+`
+
+func TestCompactInput(t *testing.T) {
+	prev := strings.NewReplacer("-       24", "-       8", "-       31048", "-       31024").Replace(ataOutput)
+	got := compactInput(smartdata.SMARTDevice{Device: "/dev/sda", SMARTData: ataOutput},
+		smartdata.SMARTDevice{Device: "/dev/sda", SMARTData: prev}, defaultInputTokens)
+
+	for _, want := range []string{
+		"Device: /dev/sda\nModel family: Western Digital Red\nModel: WDC WD40EFRX-68N32N0\n",
+		"Capacity: 4.00 TB\nSMART overall health: PASSED\n",
+		"reallocated_sectors: 24 (+16)\n",
+		"power_on_hours: 31048 (+24)\n",
+		"5 Reallocated_Sector_Ct 196/196/140 raw 24 (was 8)\n",
+		"Error 7 occurred at disk power-on lifetime: 31040 hours (1293 days + 8 hours) - UNC 8 sectors at LBA = 0x00123456 = 1193046\n",
+		"Recent self-tests (Num Test_Description Status Remaining LifeTime(hours) LBA_of_first_error):\n# 1 Short offline Completed: read failure 90% 31045 1193046\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("input does not contain %q:\n%s", want, got)
+		}
+	}
+	// атрибуты без значения для оценки не передаются
+	for _, skip := range []string{"Spin_Up_Time", "Calibration_Retry_Count"} {
+		if strings.Contains(got, skip) {
+			t.Errorf("input contains %s", skip)
+		}
+	}
+
+	// при малом бюджете сведения о диске и показатели остаются, а журналы
+	// сокращаются с отметкой о пропуске
+	short := compactInput(smartdata.SMARTDevice{Device: "/dev/sda", SMARTData: ataOutput}, smartdata.SMARTDevice{}, 100)
+	for _, want := range []string{
+		"reallocated_sectors: 24\n",
+		"5 Reallocated_Sector_Ct 196/196/140 raw 24\n(2 more lines omitted)",
+		"Recent error log entries:\n(2 lines omitted)",
+	} {
+		if !strings.Contains(short, want) {
+			t.Errorf("short input does not contain %q:\n%s", want, short)
+		}
+	}
+}
+
+func TestMessages_Prompt(t *testing.T) {
+	s := NewLLMDescriber(nil, i18n.EN, 0)
+	dev := smartdata.SMARTDevice{Device: "/dev/sda", SMARTData: ataOutput}
+	for _, tc := range []struct {
+		input string
+		prev  smartdata.SMARTDevice
+		want  string
+	}{
+		{InputCompact, smartdata.SMARTDevice{}, NewDiskCompactSysPrompt},
+		{InputCompact, dev, CompareCompactSysPrompt},
+		{InputRaw, smartdata.SMARTDevice{}, NewDiskSysPrompt},
+		{InputRaw, dev, CompareSysPrompt},
+	} {
+		msgs := s.messages(ProviderConfig{Input: tc.input}, dev, tc.prev)
+		if got := msgs[0].OfSystem.Content.OfString.Value; got != s.prompt(tc.want) {
+			t.Errorf("input %q, prev %t: wrong system prompt:\n%s", tc.input, tc.prev.SMARTData != "", got)
+		}
+	}
+}
@@ -2,33 +2,49 @@ package llmdesc
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/covrom/smart-control/internal/i18n"
 	"github.com/covrom/smart-control/internal/smartdata"
+	"github.com/covrom/smart-control/internal/store"
 	"github.com/openai/openai-go/v3"
-	"github.com/openai/openai-go/v3/option"
 )
 
+// LLMSmartDescriber запрашивает заключения у поставщиков LLM по порядку:
+// если первый недоступен, отвечает следующий
 type LLMSmartDescriber struct {
-	mu    sync.Mutex
-	llm   openai.Client
-	model string
+	mu        sync.Mutex
+	providers []*provider
+	lang      i18n.Lang     // язык ответа
+	maxAge    time.Duration // срок повтора заключения без запроса, см. Analyze
+
+	described atomic.Int64 // заключений, запрошенных у LLM через Analyze
+	reused    atomic.Int64 // заключений, повторённых без запроса
 }
 
-func NewLLMDescriber(baseUrl, apiKey, model string) *LLMSmartDescriber {
-	llm := openai.NewClient(
-		option.WithBaseURL(baseUrl), // http://192.168.169.22:37777/v1
-		option.WithAPIKey(apiKey),   // 1234
-	)
-	ret := &LLMSmartDescriber{
-		llm:   llm,
-		model: model, // qwen3-30b-a3b-instruct-2507 | qvikhr-3-4b-instruction | qwen3-4b-instruct-2507
+// NewLLMDescriber создаёт клиента LLM с цепочкой проверенных поставщиков
+// providers, отвечающего на языке lang. Заключение снимка без значимых
+// изменений повторяется без запроса, пока ему меньше maxAge; 0 — запрашивать
+// всегда.
+func NewLLMDescriber(providers []ProviderConfig, lang i18n.Lang, maxAge time.Duration) *LLMSmartDescriber {
+	ret := &LLMSmartDescriber{lang: lang, maxAge: maxAge}
+	for _, cfg := range providers {
+		ret.providers = append(ret.providers, newProvider(cfg))
 	}
 	return ret
 }
 
+// Системные запросы для первого снимка диска и для сравнения с предыдущим:
+// по полному выводу smartctl -a (InputRaw) и по выжимке (InputCompact).
+// Вместо {language} подставляется название языка ответа (см. prompt).
+// Ответ ограничен схемой verdictSchema, запросы поясняют смысл её полей.
 const (
 	NewDiskSysPrompt = `You are an expert in evaluating hard drive health using S.M.A.R.T. data.  
 Carefully analyze the following key parameters from the provided 'smartctl -a' output:  
@@ -41,18 +57,12 @@ Carefully analyze the following key parameters from the provided 'smartctl -a' o
 - Any reallocated, pending, or offline uncorrectable sectors  
 - Other critical or warning-level SMART attributes  
 
-Respond **in Russian**, using a concise paragraph followed by a structured summary with emojis:  
-✅ = good condition, ⚠️ = requires attention, 🔴 = critical issue  
-
-Use this exact response format:
-
-[Brand] [Model], [Type: HDD/SSD/NVMe], [Form factor], [Memory type if applicable], TBW: [value], MTBF: [value], Speed: [value], Capacity: [value].
-
-✅/⚠️/🔴 [Brief overall assessment highlighting key findings]
-
-🔧 [Actionable advice: e.g., backup data, replace soon, monitor, etc.]
-
-📌 [Critical note if needed: e.g., imminent failure risk, high reallocated sectors, etc.]
+Respond with a single JSON object matching the provided schema. Write all texts **in {language}**:
+- "severity": "ok" = good condition, "warning" = requires attention, "critical" = critical issue
+- "headline": brief overall assessment highlighting key findings
+- "findings": key observations with the values that matter; critical notes such as imminent failure risk or high reallocated sectors go first
+- "actions": actionable advice: e.g., backup data, replace soon, monitor
+- "drive": brand, model, type (HDD/SSD/NVMe), form factor, capacity, endurance (TBW or MTBF), power-on hours and total written; use an empty string for unknown values
 `
 
 	CompareSysPrompt = `You are an expert in evaluating storage drive health using S.M.A.R.T. data.
@@ -70,67 +80,185 @@ Analyze **both the current and the previous 'smartctl -a' outputs** to assess no
 - Rate of deterioration (e.g., how fast sectors are being reallocated or spare space is being consumed)
 
 **Response rules**:
-- Respond **in Russian**
-- If **no problems detected**: Provide only a **brief, one-line assessment** with no recommendations or detailed analysis.
-- If **problems detected**: Provide a concise paragraph followed by a structured summary.
+- Respond with a single JSON object matching the provided schema. Write all texts **in {language}**
+- "severity": "ok" = good condition, "warning" = requires attention, "critical" = critical issue
+- If **no problems detected**: "severity" is "ok", "headline" is a **brief, one-line assessment**, "findings" and "actions" are empty.
+- If **problems detected**: "headline" is a concise assessment highlighting the changes, "findings" lists the degradation observed (speed of deterioration, sudden error spikes, imminent failure risk), "actions" lists actionable advice.
+- Growth in power-on hours or total LBA written (TBW) is expected over time and must not be interpreted as a problem.
+- "drive": brand, model, type (HDD/SSD/NVMe), form factor, capacity, endurance (TBW or MTBF), power-on hours and total written (terabytes); use an empty string for unknown values
+`
+
+	NewDiskCompactSysPrompt = `You are an expert in evaluating hard drive health using S.M.A.R.T. data.
+You are given a condensed summary extracted from a 'smartctl -a' output, not the full output:
+- drive identity: device, model family, model, serial, firmware, capacity and the overall SMART health result
+- "Metrics:" — parsed values, one per line as "name: value"; data volumes are in terabytes
+- "ATA attributes" (ATA drives only) — relevant attributes and any attribute that failed or is near its threshold, as "ID name value/worst/thresh raw RAW", with "WHEN_FAILED" if set
+- "Recent error log entries" and "Recent self-tests" — the most recent log entries, newest first
+A section may end with "(N more lines omitted)" or consist of "(N lines omitted)" only: these lines exist on the drive but did not fit the input, so never treat an omitted log as empty. A missing section means the drive reported no such data.
+
+Carefully analyze:
+- Drive temperature
+- Power-on hours (age)
+- Total host reads/writes (data volume handled)
+- Reallocated, pending and offline uncorrectable sectors
+- Uncorrectable and interface CRC errors, media errors and error log entries
+- Wear leveling, available spare, percentage used, or other endurance indicators (especially for SSDs/NVMe)
+- Failed self-tests and other critical or warning-level SMART attributes
 
-Use this exact response format:
+Respond with a single JSON object matching the provided schema. Write all texts **in {language}**:
+- "severity": "ok" = good condition, "warning" = requires attention, "critical" = critical issue
+- "headline": brief overall assessment highlighting key findings
+- "findings": key observations with the values that matter; critical notes such as imminent failure risk or high reallocated sectors go first
+- "actions": actionable advice: e.g., backup data, replace soon, monitor
+- "drive": brand, model, type (HDD/SSD/NVMe), form factor, capacity, endurance (TBW or MTBF), power-on hours and total written; use an empty string for unknown values
+`
 
-[Brand] [Model], [Type: HDD/SSD/NVMe], [Form factor], [Memory type if applicable], TBW: [value], MTBF: [value], Speed: [value], Capacity: [value], Power-on hours: [value], Total written: [terabytes].
+	CompareCompactSysPrompt = `You are an expert in evaluating storage drive health using S.M.A.R.T. data.
+You are given a condensed summary extracted from the **current** 'smartctl -a' output, with changes since the previous output marked inline:
+- drive identity: device, model family, model, serial, firmware, capacity and the overall SMART health result
+- "Metrics:" — parsed values, one per line as "name: value (+delta)" or "name: value (-delta)"; the delta in parentheses is the change since the previous output and is absent if the value did not change; data volumes are in terabytes
+- "ATA attributes" (ATA drives only) — relevant attributes and any attribute that failed or is near its threshold, as "ID name value/worst/thresh raw RAW", followed by "(was OLD)" if the raw value changed and "WHEN_FAILED" if set
+- "Recent error log entries" and "Recent self-tests" — the most recent log entries, newest first
+A section may end with "(N more lines omitted)" or consist of "(N lines omitted)" only: these lines exist on the drive but did not fit the input, so never treat an omitted log as empty. A missing section means the drive reported no such data.
 
-✅/⚠️/🔴 [Brief overall assessment — only highlight changes if issues exist.]
+Assess not only the absolute values but also **trends and changes over time**.
 
-🔧 [Actionable advice — **only if problems are present**. Growth in power-on hours or total LBA written (TBW) is expected over time and must not be interpreted as a problem]
+**Important**: A detailed assessment of drive condition is required **only if problems are detected**.
+- An increase in recorded data (e.g., power-on hours, total host reads/writes) is **not** a problem by itself — it is normal operational growth.
+- Do **not** generate recommendations or detailed analysis if no issues are present.
 
-📌 [Critical observation about degradation speed, sudden error spikes, or imminent failure risk — **only if problems are present**. Growth in power-on hours or total LBA written (TBW) is expected over time and must not be interpreted as a problem.]
+**Specifically evaluate for signs of degradation or failure**:
+- Changes in temperature (unexpected spikes or sustained high temps)
+- **Increase** in reallocated sectors, pending sectors, uncorrectable errors, offline uncorrectable counts, media errors or error log entries
+- Degradation in wear leveling, available spare or percentage used (for SSDs/NVMe)
+- Attributes that newly failed or approach their thresholds, failed self-tests
+- Rate of deterioration (e.g., how fast sectors are being reallocated or spare space is being consumed)
+
+**Response rules**:
+- Respond with a single JSON object matching the provided schema. Write all texts **in {language}**
+- "severity": "ok" = good condition, "warning" = requires attention, "critical" = critical issue
+- If **no problems detected**: "severity" is "ok", "headline" is a **brief, one-line assessment**, "findings" and "actions" are empty.
+- If **problems detected**: "headline" is a concise assessment highlighting the changes, "findings" lists the degradation observed (speed of deterioration, sudden error spikes, imminent failure risk), "actions" lists actionable advice.
+- Growth in power-on hours or total written is expected over time and must not be interpreted as a problem.
+- "drive": brand, model, type (HDD/SSD/NVMe), form factor, capacity, endurance (TBW or MTBF), power-on hours and total written (terabytes); use an empty string for unknown values
 `
 )
 
-func (s *LLMSmartDescriber) Describe(ctx context.Context, hostname string, dev, prev smartdata.SMARTDevice) string {
+// maxSchemaAttempts — сколько раз запрашивать ответ, не прошедший проверку по схеме
+const maxSchemaAttempts = 3
+
+// prompt подставляет в текст запроса язык ответа
+func (s *LLMSmartDescriber) prompt(text string) string {
+	return strings.ReplaceAll(text, "{language}", s.lang.Name())
+}
+
+// Describe запрашивает у LLM заключение о состоянии устройства по схеме
+// verdictSchema, сравнивая с предыдущим снимком prev, если он есть. Поставщики
+// опрашиваются по порядку, с разомкнутой цепью пропускаются. Возвращает nil,
+// если заключение не получено ни от одного поставщика.
+func (s *LLMSmartDescriber) Describe(ctx context.Context, hostname string, dev, prev smartdata.SMARTDevice) *store.Analysis {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	slog.Info("llm description begin", "hostname", hostname, "device", dev.Device)
 
-	text := dev.SMARTData
-
-	var messages []openai.ChatCompletionMessageParamUnion
-
-	if prev.SMARTData == "" {
-		messages = []openai.ChatCompletionMessageParamUnion{
-			openai.SystemMessage(NewDiskSysPrompt),
-			openai.UserMessage(
-				fmt.Sprintf("Now analyze the following 'smartctl -a' output and provide your assessment (in Russian):\n\n%s", text),
-			),
+	for _, p := range s.providers {
+		if !p.available(time.Now()) {
+			slog.Info("llm provider skipped: circuit open", "provider", p.cfg.Name)
+			continue
+		}
+		a, err := s.describeWith(ctx, p, s.messages(p.cfg, dev, prev))
+		if err == nil {
+			a.Provider, a.Model = p.cfg.Name, p.cfg.Model
+			slog.Info("llm description done", "hostname", hostname, "device", dev.Device,
+				"provider", p.cfg.Name, "model", p.cfg.Model, "severity", a.Level)
+			return a
 		}
-	} else {
-		messages = []openai.ChatCompletionMessageParamUnion{
-			openai.SystemMessage(CompareSysPrompt),
-			openai.UserMessage(
-				fmt.Sprintf(`Now analyze the following data and provide your assessment (in Russian):
+		if ctx.Err() != nil {
+			return nil
+		}
+		slog.Error("llm provider failed", "hostname", hostname, "device", dev.Device, "provider", p.cfg.Name, "err", err)
+	}
+	slog.Error("llm description failed: no provider answered", "hostname", hostname, "device", dev.Device)
+	return nil
+}
+
+// messages составляет запрос по текущему и предыдущему снимкам в виде,
+// заданном настройками поставщика cfg: выжимкой или полным выводом smartctl
+func (s *LLMSmartDescriber) messages(cfg ProviderConfig, dev, prev smartdata.SMARTDevice) []openai.ChatCompletionMessageParamUnion {
+	var sys, user string
+	switch {
+	case cfg.Input == InputRaw && prev.SMARTData == "":
+		sys = NewDiskSysPrompt
+		user = fmt.Sprintf("Now analyze the following 'smartctl -a' output and provide your assessment (in %s):\n\n%s", s.lang.Name(), dev.SMARTData)
+	case cfg.Input == InputRaw:
+		sys = CompareSysPrompt
+		user = fmt.Sprintf(`Now analyze the following data and provide your assessment (in %s):
 
 **Previous 'smartctl -a' output:**  
 %s
 
 **Current 'smartctl -a' output:**  
 %s`,
-					prev.SMARTData, text),
-			),
+			s.lang.Name(), prev.SMARTData, dev.SMARTData)
+	default:
+		budget := cfg.MaxInputTokens
+		if budget == 0 {
+			budget = defaultInputTokens
+		}
+		data := compactInput(dev, prev, budget)
+		if prev.SMARTData == "" {
+			sys = NewDiskCompactSysPrompt
+			user = fmt.Sprintf("Now analyze the following data extracted from the 'smartctl -a' output and provide your assessment (in %s):\n\n%s", s.lang.Name(), data)
+		} else {
+			sys = CompareCompactSysPrompt
+			user = fmt.Sprintf("Now analyze the following data extracted from the current 'smartctl -a' output and provide your assessment (in %s). "+
+				"Values in parentheses are changes since the previous output:\n\n%s", s.lang.Name(), data)
 		}
 	}
-
-	chatCompletion, err := s.llm.Chat.Completions.New(
-		ctx,
-		openai.ChatCompletionNewParams{
-			Messages: messages,
-			Model:    s.model,
-		})
-	if err != nil {
-		slog.Error("llm.Chat.Completions.New error", "err", err)
-		return ""
+	return []openai.ChatCompletionMessageParamUnion{
+		openai.SystemMessage(s.prompt(sys)),
+		openai.UserMessage(user),
 	}
+}
 
-	slog.Info("llm description done", "hostname", hostname, "device", dev.Device)
+// describeWith запрашивает заключение у поставщика p. Ответ, не прошедший
+// проверку по схеме, запрашивается повторно с указанием ошибки; такие ответы
+// не размыкают цепь — поставщик доступен, но модель не справилась.
+func (s *LLMSmartDescriber) describeWith(ctx context.Context, p *provider, messages []openai.ChatCompletionMessageParamUnion) (*store.Analysis, error) {
+	params := openai.ChatCompletionNewParams{
+		Messages: slices.Clone(messages),
+		Model:    p.cfg.Model,
+		ResponseFormat: openai.ChatCompletionNewParamsResponseFormatUnion{
+			OfJSONSchema: &openai.ResponseFormatJSONSchemaParam{
+				JSONSchema: openai.ResponseFormatJSONSchemaJSONSchemaParam{
+					Name:   "smart_verdict",
+					Strict: openai.Bool(true),
+					Schema: verdictSchema,
+				},
+			},
+		},
+	}
+	var err error
+	for attempt := 1; attempt <= maxSchemaAttempts; attempt++ {
+		chatCompletion, cerr := p.complete(ctx, params)
+		if cerr != nil {
+			return nil, cerr
+		}
+		if len(chatCompletion.Choices) == 0 {
+			return nil, errors.New("no choices in response")
+		}
 
-	return chatCompletion.Choices[0].Message.Content
+		content := chatCompletion.Choices[0].Message.Content
+		var v verdict
+		if v, err = parseVerdict(content); err == nil {
+			return v.analysis(s.lang), nil
+		}
+		slog.Warn("llm response does not match schema", "provider", p.cfg.Name, "attempt", attempt, "err", err)
+		params.Messages = append(params.Messages,
+			openai.AssistantMessage(content),
+			openai.UserMessage(fmt.Sprintf("The response does not match the JSON schema: %v. Reply again with a single JSON object that matches the schema.", err)),
+		)
+	}
+	return nil, fmt.Errorf("no valid response after %d attempts: %w", maxSchemaAttempts, err)
 }
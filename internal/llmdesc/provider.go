@@ -0,0 +1,209 @@
+package llmdesc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/option"
+)
+
+const (
+	// defaultTimeout — предельное время одного запроса, если оно не задано:
+	// локальные модели на слабом железе отвечают минутами
+	defaultTimeout = 5 * time.Minute
+	// defaultAttempts — попыток запроса при временных ошибках, если не задано
+	defaultAttempts = 3
+	// retryBackoff — пауза перед вторым запросом, далее удваивается
+	retryBackoff = 2 * time.Second
+
+	// после breakerThreshold неудачных обращений подряд поставщик пропускается
+	// в течение breakerCooldown, затем снова получает один пробный запрос
+	breakerThreshold = 3
+	breakerCooldown  = 5 * time.Minute
+)
+
+// ProviderConfig — настройки поставщика LLM с API, совместимым с OpenAI
+// (llama.cpp, Ollama, OpenRouter и т.п.)
+type ProviderConfig struct {
+	Name    string `json:"name"`
+	BaseURL string `json:"base_url"` // например, http://192.168.1.1:8080/v1
+	APIKey  string `json:"api_key,omitempty"`
+	Model   string `json:"model"`
+	// Timeout — предельное время одного запроса в формате time.ParseDuration,
+	// по умолчанию 5m
+	Timeout string `json:"timeout,omitempty"`
+	// Attempts — число попыток запроса при временных ошибках (сеть, тайм-аут,
+	// 408, 429, 5xx), по умолчанию 3
+	Attempts int `json:"attempts,omitempty"`
+	// Input — что передавать модели: compact (по умолчанию) — выжимку
+	// из показателей, raw — полный вывод smartctl -a
+	Input string `json:"input,omitempty"`
+	// MaxInputTokens — бюджет выжимки в токенах, по умолчанию 2000
+	MaxInputTokens int `json:"max_input_tokens,omitempty"`
+}
+
+// Validate проверяет настройки поставщика
+func (c ProviderConfig) Validate() error {
+	u, err := url.Parse(c.BaseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("llm provider %q: invalid base_url %q", c.Name, c.BaseURL)
+	}
+	if c.Model == "" {
+		return fmt.Errorf("llm provider %q: no model", c.Name)
+	}
+	if _, err := c.timeout(); err != nil {
+		return fmt.Errorf("llm provider %q: %w", c.Name, err)
+	}
+	if c.Attempts < 0 {
+		return fmt.Errorf("llm provider %q: attempts must not be negative", c.Name)
+	}
+	switch c.Input {
+	case "", InputCompact, InputRaw:
+	default:
+		return fmt.Errorf("llm provider %q: input must be %s or %s, got %q", c.Name, InputCompact, InputRaw, c.Input)
+	}
+	if c.MaxInputTokens < 0 {
+		return fmt.Errorf("llm provider %q: max_input_tokens must not be negative", c.Name)
+	}
+	return nil
+}
+
+func (c ProviderConfig) timeout() (time.Duration, error) {
+	if c.Timeout == "" {
+		return defaultTimeout, nil
+	}
+	d, err := time.ParseDuration(c.Timeout)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timeout: %w", err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("timeout must be positive, got %s", c.Timeout)
+	}
+	return d, nil
+}
+
+// provider — клиент поставщика с учётом неудач подряд для размыкания цепи.
+// Запросы к нему последовательны (под мьютексом LLMSmartDescriber), а mu
+// защищает счётчики, которые читаются и при выводе метрик.
+type provider struct {
+	cfg      ProviderConfig
+	client   openai.Client
+	attempts int
+
+	mu        sync.Mutex
+	failures  int       // неудачных обращений подряд
+	openUntil time.Time // до этого момента поставщик пропускается
+	succeeded int       // обращений с ответом с запуска сервера
+	failed    int       // неудачных обращений с запуска сервера
+}
+
+func newProvider(cfg ProviderConfig) *provider {
+	timeout, err := cfg.timeout()
+	if err != nil {
+		timeout = defaultTimeout
+	}
+	attempts := cfg.Attempts
+	if attempts == 0 {
+		attempts = defaultAttempts
+	}
+	return &provider{
+		cfg: cfg,
+		// повторы выполняет сам provider, чтобы учитывать их в размыкании цепи
+		client: openai.NewClient(
+			option.WithBaseURL(cfg.BaseURL),
+			option.WithAPIKey(cfg.APIKey),
+			option.WithRequestTimeout(timeout),
+			option.WithMaxRetries(0),
+		),
+		attempts: attempts,
+	}
+}
+
+// available сообщает, можно ли обращаться к поставщику: цепь замкнута или
+// истёк срок размыкания
+func (p *provider) available(now time.Time) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return !now.Before(p.openUntil)
+}
+
+// complete выполняет запрос, повторяя его с растущей паузой при временных
+// ошибках. Неудача после всех попыток засчитывается в размыкание цепи.
+func (p *provider) complete(ctx context.Context, params openai.ChatCompletionNewParams) (*openai.ChatCompletion, error) {
+	backoff := retryBackoff
+	for attempt := 1; ; attempt++ {
+		resp, err := p.client.Chat.Completions.New(ctx, params)
+		if err == nil {
+			p.succeed()
+			return resp, nil
+		}
+		if ctx.Err() != nil {
+			// остановка сервера — не вина поставщика
+			return nil, ctx.Err()
+		}
+		if attempt >= p.attempts || !transient(err) {
+			p.fail(time.Now())
+			return nil, err
+		}
+		slog.Warn("llm request failed, retrying", "provider", p.cfg.Name, "attempt", attempt, "err", err)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// succeed учитывает ответ и замыкает цепь
+func (p *provider) succeed() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.succeeded++
+	p.failures, p.openUntil = 0, time.Time{}
+}
+
+// fail учитывает неудачу и размыкает цепь после breakerThreshold неудач подряд.
+// Неудачный пробный запрос после паузы снова размыкает цепь.
+func (p *provider) fail(now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.failed++
+	p.failures++
+	if p.failures >= breakerThreshold {
+		p.openUntil = now.Add(breakerCooldown)
+		slog.Warn("llm provider circuit open", "provider", p.cfg.Name, "failures", p.failures, "until", p.openUntil)
+	}
+}
+
+// stats — счётчики поставщика на момент now
+func (p *provider) stats(now time.Time) ProviderStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return ProviderStats{
+		Name:      p.cfg.Name,
+		Model:     p.cfg.Model,
+		Succeeded: p.succeeded,
+		Failed:    p.failed,
+		Open:      now.Before(p.openUntil),
+	}
+}
+
+// transient сообщает, имеет ли смысл повторить запрос: ошибки сети
+// и тайм-ауты, 408, 429 и 5xx. Остальные ответы 4xx (неверный ключ,
+// неизвестная модель) повтором не исправить.
+func transient(err error) bool {
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) {
+		code := apiErr.StatusCode
+		return code == http.StatusRequestTimeout || code == http.StatusTooManyRequests || code >= 500
+	}
+	return true
+}
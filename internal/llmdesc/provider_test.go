@@ -0,0 +1,71 @@
+package llmdesc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/covrom/smart-control/internal/i18n"
+	"github.com/covrom/smart-control/internal/smartdata"
+)
+
+// writeCompletion отвечает как OpenAI Chat Completions с текстом content
+func writeCompletion(w http.ResponseWriter, content string) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"id": "1", "object": "chat.completion", "model": "m",
+		"choices": []any{map[string]any{"index": 0, "finish_reason": "stop",
+			"message": map[string]any{"role": "assistant", "content": content}}},
+	})
+}
+
+func TestDescribe_Fallback(t *testing.T) {
+	var localCalls, remoteCalls int
+	local := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		localCalls++
+		http.Error(w, `{"error": {"message": "model not loaded"}}`, http.StatusServiceUnavailable)
+	}))
+	defer local.Close()
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remoteCalls++
+		writeCompletion(w, validVerdict)
+	}))
+	defer remote.Close()
+
+	d := NewLLMDescriber([]ProviderConfig{
+		{Name: "local", BaseURL: local.URL, Model: "qwen3-4b", Attempts: 1},
+		{Name: "openrouter", BaseURL: remote.URL, Model: "gpt-4o-mini"},
+	}, i18n.EN, 0)
+	dev := smartdata.SMARTDevice{Device: "/dev/sda", SMARTData: "smartctl output"}
+
+	for i := 1; i <= breakerThreshold+1; i++ {
+		a := d.Describe(context.Background(), "nas", dev, smartdata.SMARTDevice{})
+		if a == nil || a.Provider != "openrouter" || a.Model != "gpt-4o-mini" {
+			t.Fatalf("call %d: analysis = %+v", i, a)
+		}
+	}
+	// после breakerThreshold неудач подряд цепь разомкнута и local пропускается
+	if localCalls != breakerThreshold || remoteCalls != breakerThreshold+1 {
+		t.Errorf("calls: local %d, remote %d", localCalls, remoteCalls)
+	}
+}
+
+func TestProviderConfig_Validate(t *testing.T) {
+	ok := ProviderConfig{Name: "local", BaseURL: "http://127.0.0.1:8080/v1", Model: "m", Timeout: "90s"}
+	if err := ok.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	for _, bad := range []ProviderConfig{
+		{Name: "a", BaseURL: "127.0.0.1:8080", Model: "m"},
+		{Name: "a", BaseURL: "http://127.0.0.1/v1"},
+		{Name: "a", BaseURL: "http://127.0.0.1/v1", Model: "m", Timeout: "soon"},
+		{Name: "a", BaseURL: "http://127.0.0.1/v1", Model: "m", Timeout: "-1s"},
+		{Name: "a", BaseURL: "http://127.0.0.1/v1", Model: "m", Attempts: -1},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("%+v: expected error", bad)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package llmdesc
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/covrom/smart-control/internal/i18n"
+	"github.com/covrom/smart-control/internal/smartdata"
+	"github.com/covrom/smart-control/internal/store"
+)
+
+// Analyze возвращает заключение для нового снимка snap. Если отпечаток
+// показателей (smartdata.Metrics.Fingerprint) не изменился с предыдущего
+// снимка prev, а заключению prev меньше maxAge, оно повторяется с обновлёнными
+// наработкой и объёмом записи без запроса к LLM. Иначе заключение
+// запрашивается через Describe.
+func (s *LLMSmartDescriber) Analyze(ctx context.Context, hostname string, snap, prev store.Snapshot) *store.Analysis {
+	fp := snap.Metrics.Fingerprint()
+	if a, ok := reuse(prev.Analysis, fp, snap.Metrics, time.Now(), s.maxAge, s.lang); ok {
+		s.reused.Add(1)
+		slog.Info("llm analysis reused: no material changes", "hostname", hostname, "device", snap.Device.Device,
+			"created_at", a.CreatedAt, "severity", a.Level)
+		return a
+	}
+	s.described.Add(1)
+	a := s.Describe(ctx, hostname, snap.Device, prev.Device)
+	if a != nil {
+		a.Fingerprint = fp
+	}
+	return a
+}
+
+// reuse повторяет заключение prev для снимка с показателями m и отпечатком fp.
+// Повторяются только заключения по схеме: текст собирается заново из полей
+// на языке l.
+func reuse(prev *store.Analysis, fp string, m smartdata.Metrics, now time.Time, maxAge time.Duration, l i18n.Lang) (*store.Analysis, bool) {
+	if maxAge <= 0 || prev == nil || prev.Drive == nil || fp == "" || prev.Fingerprint != fp ||
+		now.Sub(prev.CreatedAt) >= maxAge {
+		return nil, false
+	}
+
+	a := *prev
+	drive := *prev.Drive
+	if v, ok := m.Value(smartdata.MetricPowerOnHours); ok {
+		drive.PowerOnHours = strconv.FormatFloat(v, 'f', 0, 64)
+	}
+	if v, ok := m.Value(smartdata.MetricBytesWritten); ok {
+		drive.TotalWritten = fmt.Sprintf("%.2f TB", v/1e12)
+	}
+	a.Drive = &drive
+	a.Text = verdict{Severity: a.Level, Headline: a.Headline, Findings: a.Findings, Actions: a.Actions, Drive: drive}.text(l)
+	a.RefreshedAt = now
+	return &a, true
+}
+
+// Stats — счётчики обращений к LLM с запуска сервера
+type Stats struct {
+	Described int64 // снимков, для которых заключение запрошено у LLM
+	Reused    int64 // снимков, для которых повторено прежнее заключение
+	Providers []ProviderStats
+}
+
+// ProviderStats — счётчики запросов к поставщику
+type ProviderStats struct {
+	Name      string
+	Model     string
+	Succeeded int  // запросов с ответом
+	Failed    int  // неудачных обращений после всех попыток
+	Open      bool // цепь разомкнута, поставщик пропускается
+}
+
+// Stats возвращает счётчики для метрик
+func (s *LLMSmartDescriber) Stats() Stats {
+	now := time.Now()
+	ret := Stats{Described: s.described.Load(), Reused: s.reused.Load()}
+	for _, p := range s.providers {
+		ret.Providers = append(ret.Providers, p.stats(now))
+	}
+	return ret
+}
@@ -0,0 +1,56 @@
+package llmdesc
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/covrom/smart-control/internal/i18n"
+	"github.com/covrom/smart-control/internal/smartdata"
+	"github.com/covrom/smart-control/internal/store"
+)
+
+func TestReuse(t *testing.T) {
+	v, err := parseVerdict(validVerdict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	prev := v.analysis(i18n.EN)
+	prev.Fingerprint, prev.CreatedAt = "fp", now.Add(-48*time.Hour)
+	m := smartdata.Metrics{Values: map[string]float64{
+		smartdata.MetricPowerOnHours: 31048,
+		smartdata.MetricBytesWritten: 12.5e12,
+	}}
+
+	a, ok := reuse(prev, "fp", m, now, 7*24*time.Hour, i18n.EN)
+	if !ok {
+		t.Fatal("not reused")
+	}
+	if a.Drive.PowerOnHours != "31048" || a.Drive.TotalWritten != "12.50 TB" || !a.RefreshedAt.Equal(now) || !a.CreatedAt.Equal(prev.CreatedAt) {
+		t.Errorf("reused = %+v %+v", a, a.Drive)
+	}
+	if !strings.Contains(a.Text, "Power-on hours: 31048") || a.Level != store.LevelWarning {
+		t.Errorf("text = %q", a.Text)
+	}
+	if prev.Drive.PowerOnHours != "31000" {
+		t.Error("previous analysis modified")
+	}
+
+	for name, c := range map[string]struct {
+		prev   *store.Analysis
+		fp     string
+		maxAge time.Duration
+	}{
+		"changed":  {prev, "other", 7 * 24 * time.Hour},
+		"unknown":  {prev, "", 7 * 24 * time.Hour},
+		"expired":  {prev, "fp", 24 * time.Hour},
+		"disabled": {prev, "fp", 0},
+		"none":     {nil, "fp", 7 * 24 * time.Hour},
+		"legacy":   {&store.Analysis{Text: "✅ ok", Fingerprint: "fp", CreatedAt: now}, "fp", 7 * 24 * time.Hour},
+	} {
+		if _, ok := reuse(c.prev, c.fp, m, now, c.maxAge, i18n.EN); ok {
+			t.Errorf("%s: reused", name)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package llmdesc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/covrom/smart-control/internal/i18n"
+	"github.com/covrom/smart-control/internal/store"
+)
+
+// verdict — заключение LLM по схеме verdictSchema
+type verdict struct {
+	Severity store.Level `json:"severity"`
+	Headline string      `json:"headline"`
+	Findings []string    `json:"findings"`
+	Actions  []string    `json:"actions"`
+	Drive    store.Drive `json:"drive"`
+}
+
+// verdictSchema — JSON Schema ответа LLM для response_format. В строгом
+// режиме все поля обязательны, поэтому неизвестные значения — пустые строки.
+var verdictSchema = map[string]any{
+	"type":                 "object",
+	"additionalProperties": false,
+	"required":             []string{"severity", "headline", "findings", "actions", "drive"},
+	"properties": map[string]any{
+		"severity": map[string]any{"type": "string", "enum": []store.Level{store.LevelOK, store.LevelWarning, store.LevelCritical}},
+		"headline": map[string]any{"type": "string"},
+		"findings": map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+		"actions":  map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+		"drive": map[string]any{
+			"type":                 "object",
+			"additionalProperties": false,
+			"required":             driveFields,
+			"properties": func() map[string]any {
+				ret := make(map[string]any, len(driveFields))
+				for _, f := range driveFields {
+					ret[f] = map[string]any{"type": "string"}
+				}
+				return ret
+			}(),
+		},
+	},
+}
+
+// driveFields — поля store.Drive в ответе LLM
+var driveFields = []string{"brand", "model", "type", "form_factor", "capacity", "endurance", "power_on_hours", "total_written"}
+
+// parseVerdict разбирает ответ LLM и проверяет его по схеме
+func parseVerdict(content string) (verdict, error) {
+	content = strings.TrimSpace(content)
+	// модели без поддержки response_format иногда оборачивают JSON в блок кода
+	if s, ok := strings.CutPrefix(content, "```"); ok {
+		s = strings.TrimPrefix(s, "json")
+		content = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), "```"))
+	}
+	if content == "" {
+		return verdict{}, errors.New("empty response")
+	}
+
+	var v verdict
+	dec := json.NewDecoder(strings.NewReader(content))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&v); err != nil {
+		return verdict{}, fmt.Errorf("invalid JSON: %w", err)
+	}
+	if dec.More() {
+		return verdict{}, errors.New("extra data after the JSON object")
+	}
+	switch v.Severity {
+	case store.LevelOK, store.LevelWarning, store.LevelCritical:
+	default:
+		return verdict{}, fmt.Errorf("severity must be one of ok, warning, critical, got %q", v.Severity)
+	}
+	if strings.TrimSpace(v.Headline) == "" {
+		return verdict{}, errors.New("headline is empty")
+	}
+	return v, nil
+}
+
+// analysis переводит заключение в анализ с текстом на языке l
+func (v verdict) analysis(l i18n.Lang) *store.Analysis {
+	drive := v.Drive
+	return &store.Analysis{
+		Text:      v.text(l),
+		Level:     v.Severity,
+		Headline:  v.Headline,
+		Findings:  v.Findings,
+		Actions:   v.Actions,
+		Drive:     &drive,
+		CreatedAt: time.Now(),
+	}
+}
+
+// text — заключение в прежнем формате свободного ответа: сведения о диске,
+// значок уровня с итогом, наблюдения и рекомендации. Подписи сведений —
+// на языке l, на котором пишет и LLM.
+func (v verdict) text(l i18n.Lang) string {
+	d := v.Drive
+	var facts []string
+	add := func(label, value string) {
+		if value = strings.TrimSpace(value); value != "" {
+			facts = append(facts, label+value)
+		}
+	}
+	add("", strings.TrimSpace(d.Brand+" "+d.Model))
+	add("", d.Type)
+	add("", d.FormFactor)
+	add(l.T("verdict.capacity"), d.Capacity)
+	add(l.T("verdict.endurance"), d.Endurance)
+	add(l.T("verdict.power_on_hours"), d.PowerOnHours)
+	add(l.T("verdict.total_written"), d.TotalWritten)
+
+	var b strings.Builder
+	if len(facts) > 0 {
+		b.WriteString(strings.Join(facts, ", ") + ".\n\n")
+	}
+	fmt.Fprintf(&b, "%s %s", v.Severity.Emoji(), v.Headline)
+	for _, f := range v.Findings {
+		fmt.Fprintf(&b, "\n- %s", f)
+	}
+	if len(v.Actions) > 0 {
+		b.WriteString("\n")
+		for _, a := range v.Actions {
+			fmt.Fprintf(&b, "\n🔧 %s", a)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,88 @@
+package llmdesc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/covrom/smart-control/internal/i18n"
+	"github.com/covrom/smart-control/internal/smartdata"
+	"github.com/covrom/smart-control/internal/store"
+)
+
+const validVerdict = `{"severity": "warning", "headline": "Reallocated sectors are growing",
+	"findings": ["Reallocated_Sector_Ct 8 → 24"], "actions": ["Back up the data"],
+	"drive": {"brand": "WDC", "model": "WD40EFRX", "type": "HDD", "form_factor": "3.5\"", "capacity": "4 TB",
+		"endurance": "", "power_on_hours": "31000", "total_written": ""}}`
+
+func TestParseVerdict(t *testing.T) {
+	v, err := parseVerdict("```json\n" + validVerdict + "\n```")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Severity != store.LevelWarning || v.Drive.Model != "WD40EFRX" {
+		t.Errorf("got %+v", v)
+	}
+	want := "WDC WD40EFRX, HDD, 3.5\", Capacity: 4 TB, Power-on hours: 31000.\n\n" +
+		"⚠️ Reallocated sectors are growing\n- Reallocated_Sector_Ct 8 → 24\n\n🔧 Back up the data"
+	if got := v.text(i18n.EN); got != want {
+		t.Errorf("text:\ngot  %q\nwant %q", got, want)
+	}
+	if got := v.text(i18n.RU); !strings.HasPrefix(got, "WDC WD40EFRX, HDD, 3.5\", Ёмкость: 4 TB, Наработка (ч): 31000.") {
+		t.Errorf("ru text: %q", got)
+	}
+	if a := v.analysis(i18n.RU); a.Verdict() != store.LevelWarning || store.LevelFromText(a.Text) != store.LevelWarning {
+		t.Errorf("analysis level = %v", a.Verdict())
+	}
+
+	for _, bad := range []string{
+		"",
+		"✅ all good",
+		`{"severity": "fine", "headline": "x", "findings": [], "actions": [], "drive": {}}`,
+		`{"severity": "ok", "headline": " ", "findings": [], "actions": [], "drive": {}}`,
+		`{"severity": "ok", "headline": "x", "extra": 1}`,
+		`{"severity": "ok", "headline": "x"} {}`,
+	} {
+		if _, err := parseVerdict(bad); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
+
+func TestDescribe_RetryOnSchemaViolation(t *testing.T) {
+	var requests []map[string]any
+	replies := []string{`{"severity": "bad"}`, validVerdict}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]any
+		json.NewDecoder(r.Body).Decode(&req)
+		requests = append(requests, req)
+		writeCompletion(w, replies[min(len(requests), len(replies))-1])
+	}))
+	defer srv.Close()
+
+	d := NewLLMDescriber([]ProviderConfig{{Name: "local", BaseURL: srv.URL, Model: "m"}}, i18n.EN, 0)
+	a := d.Describe(context.Background(), "nas", smartdata.SMARTDevice{Device: "/dev/sda", SMARTData: "smartctl output"}, smartdata.SMARTDevice{})
+	if a == nil || a.Level != store.LevelWarning || a.Headline != "Reallocated sectors are growing" {
+		t.Fatalf("analysis = %+v", a)
+	}
+	if len(requests) != 2 {
+		t.Fatalf("requests = %d", len(requests))
+	}
+	format, _ := requests[0]["response_format"].(map[string]any)
+	if format["type"] != "json_schema" {
+		t.Errorf("response_format = %v", requests[0]["response_format"])
+	}
+	// повтор содержит прежний ответ и описание ошибки
+	msgs, _ := requests[1]["messages"].([]any)
+	last, _ := msgs[len(msgs)-1].(map[string]any)
+	if len(msgs) != 4 || !strings.Contains(last["content"].(string), "severity") {
+		t.Errorf("retry messages = %v", msgs)
+	}
+	sys, _ := msgs[0].(map[string]any)
+	if !strings.Contains(sys["content"].(string), "in English") {
+		t.Errorf("system prompt without language: %v", sys["content"])
+	}
+}
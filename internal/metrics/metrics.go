@@ -0,0 +1,249 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/covrom/smart-control/internal/smartdata"
+)
+
+// Префикс имён метрик
+const namespace = "smart_"
+
+// deviceGauges — экспортируемые показатели устройства и их описание
+var deviceGauges = []struct{ Name, Help string }{
+	{smartdata.MetricTemperature, "Drive temperature in Celsius."},
+	{smartdata.MetricPowerOnHours, "Power-on hours."},
+	{smartdata.MetricPowerCycles, "Power cycle count."},
+	{smartdata.MetricReallocated, "Reallocated sectors count."},
+	{smartdata.MetricPending, "Current pending sectors count."},
+	{smartdata.MetricOfflineUncorr, "Offline uncorrectable sectors count."},
+	{smartdata.MetricCRCErrors, "UDMA CRC error count."},
+	{smartdata.MetricPercentageUsed, "Percentage of rated endurance used."},
+	{smartdata.MetricAvailableSpare, "Available spare in percent (NVMe)."},
+	{smartdata.MetricBytesWritten, "Total bytes written."},
+	{smartdata.MetricBytesRead, "Total bytes read."},
+	{smartdata.MetricMediaErrors, "Media and data integrity errors (NVMe)."},
+	{smartdata.MetricErrorLogEntries, "Error log entries."},
+	{smartdata.MetricUnsafeShutdowns, "Unsafe shutdowns (NVMe)."},
+	{smartdata.MetricCriticalWarning, "Critical warning bitmask (NVMe)."},
+	{smartdata.MetricGrownDefects, "Elements in grown defect list (SCSI)."},
+	{smartdata.MetricCapacityBytes, "Drive capacity in bytes."},
+	{smartdata.MetricHealthPassed, "SMART overall-health self-assessment: 1 passed, 0 failed."},
+}
+
+// Device — показатели одного устройства для экспорта
+type Device struct {
+	Host    string
+	Device  string
+	Metrics smartdata.Metrics
+	// Verdict — итоговая оценка сервера (0 unknown, 1 ok, 2 warning, 3 critical);
+	// отрицательное значение — оценки нет, метрика не выводится
+	Verdict int
+}
+
+// Host — сведения о последнем отчёте хоста
+type Host struct {
+	Host       string
+	LastReport time.Time
+	Devices    int
+	Missing    bool // хост пропустил отчёт по своему расписанию
+}
+
+// Write выводит метрики в текстовом формате Prometheus.
+// При нулевом now давность отчётов не выводится: так делается для
+// файлов textfile-коллектора, где она устаревала бы сразу после записи.
+func Write(w io.Writer, hosts []Host, devices []Device, now time.Time) error {
+	ew := &errWriter{w: w}
+
+	if len(hosts) > 0 {
+		ew.family("report_timestamp_seconds", "Unix time of the last report from the host.")
+		for _, h := range hosts {
+			ew.sample("report_timestamp_seconds", labels("host", h.Host), float64(h.LastReport.Unix()))
+		}
+		if !now.IsZero() {
+			ew.family("report_age_seconds", "Seconds since the last report from the host.")
+			for _, h := range hosts {
+				ew.sample("report_age_seconds", labels("host", h.Host), now.Sub(h.LastReport).Seconds())
+			}
+		}
+		ew.family("host_devices", "Number of devices in the last report from the host.")
+		for _, h := range hosts {
+			ew.sample("host_devices", labels("host", h.Host), float64(h.Devices))
+		}
+		if !now.IsZero() {
+			ew.family("host_missing", "1 if the host missed its scheduled report.")
+			for _, h := range hosts {
+				ew.sample("host_missing", labels("host", h.Host), boolValue(h.Missing))
+			}
+		}
+	}
+
+	if len(devices) > 0 {
+		ew.family("device_info", "Drive identity, value is always 1.")
+		for _, d := range devices {
+			ew.sample("device_info", deviceLabels(d, "model_family", d.Metrics.ModelFamily, "firmware", d.Metrics.Firmware), 1)
+		}
+	}
+
+	for _, g := range deviceGauges {
+		first := true
+		for _, d := range devices {
+			v, ok := d.Metrics.Value(g.Name)
+			if !ok {
+				continue
+			}
+			if first {
+				ew.family(g.Name, g.Help)
+				first = false
+			}
+			ew.sample(g.Name, deviceLabels(d), v)
+		}
+	}
+
+	first := true
+	for _, d := range devices {
+		if d.Verdict < 0 {
+			continue
+		}
+		if first {
+			ew.family("verdict", "Health verdict of the last analysis: 0 unknown, 1 ok, 2 warning, 3 critical.")
+			first = false
+		}
+		ew.sample("verdict", deviceLabels(d), float64(d.Verdict))
+	}
+
+	return ew.err
+}
+
+// Queue — размер дисковой очереди сервера
+type Queue struct {
+	Name    string
+	Pending int
+	Dead    int // задачи, исчерпавшие попытки
+}
+
+// WriteQueues выводит размеры очередей: рост dead означает потерянные
+// уведомления, на него стоит настроить оповещение
+func WriteQueues(w io.Writer, queues []Queue) error {
+	ew := &errWriter{w: w}
+	if len(queues) == 0 {
+		return nil
+	}
+	ew.family("queue_pending", "Jobs waiting in the queue.")
+	for _, q := range queues {
+		ew.sample("queue_pending", labels("queue", q.Name), float64(q.Pending))
+	}
+	ew.family("queue_dead", "Jobs that exhausted their attempts.")
+	for _, q := range queues {
+		ew.sample("queue_dead", labels("queue", q.Name), float64(q.Dead))
+	}
+	return ew.err
+}
+
+// LLM — счётчики обращений к LLM с запуска сервера
+type LLM struct {
+	Described int64 // снимков, для которых заключение запрошено у LLM
+	Reused    int64 // снимков, для которых повторено прежнее заключение
+	Providers []LLMProvider
+}
+
+// LLMProvider — счётчики запросов к поставщику LLM
+type LLMProvider struct {
+	Name      string
+	Model     string
+	Succeeded int
+	Failed    int
+	Open      bool // цепь разомкнута
+}
+
+// WriteLLM выводит счётчики обращений к LLM: доля reused показывает,
+// сколько запросов сэкономлено на снимках без значимых изменений
+func WriteLLM(w io.Writer, s LLM) error {
+	ew := &errWriter{w: w}
+	ew.counter("llm_analyses_total", "Snapshots analysed since start by result: described by the LLM or reused without a request.")
+	ew.sample("llm_analyses_total", labels("result", "described"), float64(s.Described))
+	ew.sample("llm_analyses_total", labels("result", "reused"), float64(s.Reused))
+	if len(s.Providers) == 0 {
+		return ew.err
+	}
+	ew.counter("llm_requests_total", "LLM provider requests since start by result.")
+	for _, p := range s.Providers {
+		ew.sample("llm_requests_total", labels("provider", p.Name, "model", p.Model, "result", "ok"), float64(p.Succeeded))
+		ew.sample("llm_requests_total", labels("provider", p.Name, "model", p.Model, "result", "error"), float64(p.Failed))
+	}
+	ew.family("llm_circuit_open", "1 if the LLM provider is skipped after consecutive failures.")
+	for _, p := range s.Providers {
+		ew.sample("llm_circuit_open", labels("provider", p.Name, "model", p.Model), boolValue(p.Open))
+	}
+	return ew.err
+}
+
+func deviceLabels(d Device, extra ...string) string {
+	kv := append([]string{
+		"host", d.Host,
+		"device", d.Device,
+		"model", d.Metrics.Model,
+		"serial", d.Metrics.Serial,
+	}, extra...)
+	return labels(kv...)
+}
+
+// labels собирает набор меток {k="v",...} из пар ключ-значение
+func labels(kv ...string) string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i+1 < len(kv); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(kv[i])
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(kv[i+1]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+// errWriter запоминает первую ошибку записи, чтобы не проверять каждую строку
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *errWriter) printf(format string, args ...any) {
+	if ew.err == nil {
+		_, ew.err = fmt.Fprintf(ew.w, format, args...)
+	}
+}
+
+func (ew *errWriter) family(name, help string) {
+	ew.printf("# HELP %s%s %s\n# TYPE %s%s gauge\n", namespace, name, help, namespace, name)
+}
+
+func (ew *errWriter) counter(name, help string) {
+	ew.printf("# HELP %s%s %s\n# TYPE %s%s counter\n", namespace, name, help, namespace, name)
+}
+
+func (ew *errWriter) sample(name, labels string, v float64) {
+	ew.printf("%s%s%s %s\n", namespace, name, labels, formatValue(v))
+}
+
+// formatValue выводит целые значения (счётчики байт, часов) без экспоненты
+func formatValue(v float64) string {
+	if v == math.Trunc(v) && math.Abs(v) < 1e15 {
+		return strconv.FormatFloat(v, 'f', 0, 64)
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
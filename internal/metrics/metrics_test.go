@@ -0,0 +1,121 @@
+package metrics
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/covrom/smart-control/internal/smartdata"
+)
+
+func TestWrite(t *testing.T) {
+	now := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+	hosts := []Host{{Host: "nas", LastReport: now.Add(-time.Hour), Devices: 2}}
+	devices := []Device{
+		{
+			Host:    "nas",
+			Device:  "/dev/sda",
+			Metrics: smartdata.Metrics{Model: `WD "Red"`, Serial: "S1", Values: map[string]float64{smartdata.MetricTemperature: 35}},
+			Verdict: 2,
+		},
+		{
+			Host:    "nas",
+			Device:  "/dev/nvme0",
+			Metrics: smartdata.Metrics{Values: map[string]float64{smartdata.MetricTemperature: 41, smartdata.MetricBytesWritten: 2.5e12}},
+			Verdict: -1,
+		},
+	}
+
+	var b strings.Builder
+	if err := Write(&b, hosts, devices, now); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+
+	for _, want := range []string{
+		`smart_report_age_seconds{host="nas"} 3600`,
+		`smart_temperature_celsius{host="nas",device="/dev/sda",model="WD \"Red\"",serial="S1"} 35`,
+		`smart_temperature_celsius{host="nas",device="/dev/nvme0",model="",serial=""} 41`,
+		`smart_bytes_written{host="nas",device="/dev/nvme0",model="",serial=""} 2500000000000`,
+		`smart_verdict{host="nas",device="/dev/sda",model="WD \"Red\"",serial="S1"} 2`,
+	} {
+		if !strings.Contains(out, want+"\n") {
+			t.Errorf("output does not contain %q:\n%s", want, out)
+		}
+	}
+	if strings.Count(out, "# TYPE smart_temperature_celsius gauge") != 1 {
+		t.Errorf("temperature family must be declared once:\n%s", out)
+	}
+	if strings.Contains(out, "nvme0\",model=\"\",serial=\"\"} -1") {
+		t.Errorf("device without verdict must not be exported:\n%s", out)
+	}
+}
+
+func TestWriteTextfile(t *testing.T) {
+	dir := t.TempDir()
+	report := smartdata.CommonSMARTReport{
+		Hostname:  "laptop",
+		Timestamp: time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC),
+		Devices: []smartdata.SMARTDevice{
+			{Device: "/dev/sda", SMARTData: "Device Model: WD\nSMART overall-health self-assessment test result: PASSED\n"},
+			{Device: "/dev/sdb", RawError: "failed"},
+		},
+	}
+	if err := WriteTextfile(dir, report); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 || entries[0].Name() != TextfileName {
+		t.Fatalf("dir entries = %v, want only %s", entries, TextfileName)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, TextfileName))
+	out := string(data)
+	for _, want := range []string{
+		`smart_report_timestamp_seconds{host="laptop"} 1735776000`,
+		`smart_health_passed{host="laptop",device="/dev/sda",model="WD",serial=""} 1`,
+		`smart_collect_error{host="laptop",device="/dev/sdb"} 1`,
+	} {
+		if !strings.Contains(out, want+"\n") {
+			t.Errorf("textfile does not contain %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "report_age_seconds") {
+		t.Errorf("textfile must not contain report age:\n%s", out)
+	}
+}
+
+func TestWriteQueues(t *testing.T) {
+	var b strings.Builder
+	if err := WriteQueues(&b, []Queue{{Name: "messages", Pending: 3, Dead: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`smart_queue_pending{queue="messages"} 3`,
+		`smart_queue_dead{queue="messages"} 1`,
+	} {
+		if !strings.Contains(b.String(), want+"\n") {
+			t.Errorf("output does not contain %q:\n%s", want, b.String())
+		}
+	}
+}
+
+func TestWriteLLM(t *testing.T) {
+	var b strings.Builder
+	err := WriteLLM(&b, LLM{Described: 2, Reused: 28, Providers: []LLMProvider{{Name: "ollama", Model: "qwen3:4b", Succeeded: 2, Failed: 1, Open: true}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# TYPE smart_llm_analyses_total counter",
+		`smart_llm_analyses_total{result="reused"} 28`,
+		`smart_llm_requests_total{provider="ollama",model="qwen3:4b",result="error"} 1`,
+		`smart_llm_circuit_open{provider="ollama",model="qwen3:4b"} 1`,
+	} {
+		if !strings.Contains(b.String(), want+"\n") {
+			t.Errorf("output does not contain %q:\n%s", want, b.String())
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/covrom/smart-control/internal/smartdata"
+)
+
+// TextfileName — имя файла для textfile-коллектора node_exporter
+const TextfileName = "smart_control.prom"
+
+// WriteTextfile сохраняет метрики отчёта в каталог textfile-коллектора node_exporter.
+// Файл сначала пишется во временный и затем атомарно переименовывается, чтобы
+// node_exporter никогда не прочитал его частично.
+func WriteTextfile(dir string, report smartdata.CommonSMARTReport) error {
+	devices := make([]Device, 0, len(report.Devices))
+	for _, d := range report.Devices {
+		devices = append(devices, Device{
+			Host:    report.Hostname,
+			Device:  d.Device,
+			Metrics: smartdata.ParseSmartctl(d.SMARTData),
+			Verdict: -1,
+		})
+	}
+	hosts := []Host{{Host: report.Hostname, LastReport: report.Timestamp, Devices: len(report.Devices)}}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, hosts, devices, time.Time{}); err != nil {
+		return err
+	}
+	ew := &errWriter{w: &buf}
+	ew.family("collect_error", "1 if the last collection failed for the host or device.")
+	ew.sample("collect_error", labels("host", report.Hostname, "device", ""), boolValue(report.RawError != ""))
+	for _, d := range report.Devices {
+		ew.sample("collect_error", labels("host", report.Hostname, "device", d.Device), boolValue(d.RawError != ""))
+	}
+	if ew.err != nil {
+		return ew.err
+	}
+
+	tmp, err := os.CreateTemp(dir, TextfileName+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("create textfile: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write textfile: %w", err)
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("chmod textfile: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close textfile: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, TextfileName)); err != nil {
+		return fmt.Errorf("rename textfile: %w", err)
+	}
+	return nil
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,141 @@
+package msgtmpl
+
+import (
+	"html/template"
+	"time"
+
+	"github.com/covrom/smart-control/internal/alert"
+	"github.com/covrom/smart-control/internal/route"
+	"github.com/covrom/smart-control/internal/smartdata"
+	"github.com/covrom/smart-control/internal/store"
+)
+
+// Analysis — данные шаблона analysis: смена состояния диска по анализу
+type Analysis struct {
+	Alert      alert.Event // problem, reminder или recovered
+	Level      store.Level
+	PrevLevel  store.Level
+	Since      time.Time // с какого времени держится уровень (для напоминания)
+	Host       string
+	OS         string
+	Device     string
+	MountPaths []string
+	Text       template.HTML     // заключение LLM в разметке
+	Metrics    smartdata.Metrics // показатели для таблицы атрибутов (функция metricsTable)
+}
+
+// DeviceError — данные шаблона device_error: ошибка чтения данных диска
+type DeviceError struct {
+	Host   string
+	OS     string
+	Device string
+	Code   string // код ошибки smartdata.ErrCode* для текста errorText, пустой у старых агентов
+	Error  string // технические подробности от агента, пустые у ошибок сервера
+	// Metrics — показатели диска для таблицы атрибутов, если данные прочитаны,
+	// но не проанализированы (код smartdata.ErrCodeAnalysis)
+	Metrics smartdata.Metrics
+}
+
+// ReportError — данные шаблона report_error: ошибка сбора отчёта агентом
+type ReportError struct {
+	Host  string
+	OS    string
+	Code  string // код ошибки smartdata.ErrCode* для текста errorText, пустой у старых агентов
+	Error string // технические подробности от агента
+}
+
+// MissingHost — данные шаблона missing_host: хост пропустил отчёт или,
+// если Recovered, снова прислал его
+type MissingHost struct {
+	Host       string
+	OS         string
+	Recovered  bool
+	Expected   time.Time // когда ожидался отчёт
+	ReceivedAt time.Time // когда получен последний отчёт
+	LastReport time.Time // время последнего отчёта перед перерывом
+}
+
+// DeviceChange — данные шаблона device_change: диск появился (Added) или пропал
+type DeviceChange struct {
+	Host   string
+	OS     string
+	Added  bool
+	Device string
+	Model  string
+	Serial string
+}
+
+// Digest — данные шаблона digest: сводка по всем хостам за период
+type Digest struct {
+	From, To                       time.Time
+	Hosts, Devices                 int
+	OK, Warning, Critical, Unknown int // число дисков по уровням
+	Concerns                       []DigestDevice
+	MoreConcerns                   int // сколько проблемных дисков не вошло в Concerns
+	Deltas                         []DigestDelta
+	Missing                        []DigestHost
+	Failed                         []string // хосты с ошибкой сбора данных
+}
+
+// DigestDevice — проблемный диск в сводке
+type DigestDevice struct {
+	Host   string
+	Device string
+	Model  string
+	Level  store.Level
+	Since  time.Time // нулевое, если неизвестно
+}
+
+// DigestDelta — рост счётчиков диска за период
+type DigestDelta struct {
+	Host    string
+	Device  string
+	Model   string
+	Changes []string // вида «reallocated +8»
+}
+
+// DigestHost — хост без отчётов
+type DigestHost struct {
+	Host       string
+	LastReport time.Time
+}
+
+// samples — примеры данных для проверки шаблонов: по одному на каждую ветку
+// встроенных шаблонов
+var samples = func() map[route.Event][]any {
+	ts := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	return map[route.Event][]any{
+		route.EventAnalysis: {
+			Analysis{Alert: alert.EventProblem, Level: store.LevelCritical, PrevLevel: store.LevelOK, Host: "nas", OS: "linux",
+				Device: "/dev/sda", MountPaths: []string{"/", "/home"}, Text: "<b>Итог</b>",
+				Metrics: smartdata.Metrics{Values: map[string]float64{"temperature": 35}, Attributes: []smartdata.Attribute{{ID: 5, Name: "Reallocated_Sector_Ct"}}}},
+			Analysis{Alert: alert.EventReminder, Level: store.LevelWarning, PrevLevel: store.LevelWarning, Since: ts, Host: "nas", OS: "linux", Device: "/dev/sda"},
+			Analysis{Alert: alert.EventRecovered, Level: store.LevelOK, PrevLevel: store.LevelWarning, Host: "nas", OS: "linux", Device: "/dev/sda"},
+		},
+		route.EventDeviceError: {
+			DeviceError{Host: "nas", OS: "linux", Device: "/dev/sda", Code: smartdata.ErrCodeSmartctl, Error: "exit status 2"},
+			DeviceError{Host: "nas", OS: "linux", Device: "/dev/sda", Error: "exit status 2"},
+		},
+		route.EventReportError: {
+			ReportError{Host: "nas", OS: "linux", Code: smartdata.ErrCodeListDevices, Error: "smartctl not found"},
+			ReportError{Host: "nas", OS: "linux", Error: "smartctl not found"},
+		},
+		route.EventMissingHost: {
+			MissingHost{Host: "nas", OS: "linux", Expected: ts, ReceivedAt: ts, LastReport: ts},
+			MissingHost{Host: "nas", OS: "linux", Recovered: true, LastReport: ts},
+		},
+		route.EventDeviceChange: {
+			DeviceChange{Host: "nas", OS: "linux", Added: true, Device: "/dev/sdb", Model: "WDC", Serial: "WD-1"},
+			DeviceChange{Host: "nas", OS: "linux", Device: "/dev/sdb"},
+		},
+		route.EventDigest: {
+			Digest{From: ts, To: ts, Hosts: 1, Devices: 1, OK: 1},
+			Digest{From: ts, To: ts, Hosts: 2, Devices: 3, Warning: 2, Critical: 1,
+				Concerns:     []DigestDevice{{Host: "nas", Device: "/dev/sda", Model: "WDC", Level: store.LevelCritical, Since: ts}},
+				MoreConcerns: 1,
+				Deltas:       []DigestDelta{{Host: "nas", Device: "/dev/sda", Changes: []string{"reallocated +8"}}},
+				Missing:      []DigestHost{{Host: "db", LastReport: ts}},
+				Failed:       []string{"db"}},
+		},
+	}
+}()
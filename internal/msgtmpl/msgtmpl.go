@@ -0,0 +1,258 @@
+// Package msgtmpl оформляет тексты уведомлений по именованным шаблонам
+// html/template: по одному на тип события и язык. Шаблоны по умолчанию
+// встроены в программу, а файлы <событие>.tmpl из каталога настроек их
+// заменяют. Значения подставляются с экранированием, поэтому результат —
+// корректный HTML Telegram (см. пакет tghtml).
+package msgtmpl
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/covrom/smart-control/internal/i18n"
+	"github.com/covrom/smart-control/internal/route"
+	"github.com/covrom/smart-control/internal/smartdata"
+	"github.com/covrom/smart-control/internal/store"
+	"github.com/covrom/smart-control/internal/tghtml"
+)
+
+//go:embed templates/*/*.tmpl
+var defaults embed.FS
+
+// ext — расширение файлов шаблонов
+const ext = ".tmpl"
+
+// Names — события, для которых есть шаблоны
+var Names = []route.Event{
+	route.EventAnalysis,
+	route.EventDeviceError,
+	route.EventReportError,
+	route.EventMissingHost,
+	route.EventDeviceChange,
+	route.EventDigest,
+}
+
+// funcs — функции шаблонов на языке l
+func funcs(l i18n.Lang) template.FuncMap {
+	return template.FuncMap{
+		"emoji":        func(lv store.Level) string { return lv.Emoji() },
+		"levelTitle":   func(lv store.Level) string { return LevelTitle(lv, l) },
+		"datetime":     l.DateTime,
+		"date":         l.Date,
+		"join":         strings.Join,
+		"errorText":    func(code string) string { return l.T("error." + code) },
+		"metricsTable": func(m smartdata.Metrics) template.HTML { return template.HTML(MetricsTable(m, l)) },
+	}
+}
+
+// LevelTitle — название уровня для сообщений на языке l
+func LevelTitle(lv store.Level, l i18n.Lang) string {
+	switch lv {
+	case store.LevelOK, store.LevelWarning, store.LevelCritical:
+		return l.T("level." + string(lv))
+	}
+	return l.T("level.unknown")
+}
+
+// MetricsTable — моноширинная таблица атрибутов SMART (ATA) или показателей
+// (NVMe и др., у которых нет таблицы атрибутов) с заголовками на языке l
+func MetricsTable(m smartdata.Metrics, l i18n.Lang) string {
+	if len(m.Attributes) > 0 {
+		rows := make([][]string, 0, len(m.Attributes))
+		for _, a := range m.Attributes {
+			rows = append(rows, []string{strconv.Itoa(a.ID), a.Name, strconv.Itoa(a.Value),
+				strconv.Itoa(a.Worst), strconv.Itoa(a.Thresh), a.Raw})
+		}
+		return tghtml.Table([]string{l.T("table.id"), l.T("table.attribute"), l.T("table.value"),
+			l.T("table.worst"), l.T("table.thresh"), l.T("table.raw")}, rows)
+	}
+	if len(m.Values) == 0 {
+		return ""
+	}
+	names := slices.Sorted(maps.Keys(m.Values))
+	rows := make([][]string, 0, len(names))
+	for _, n := range names {
+		rows = append(rows, []string{n, strconv.FormatFloat(m.Values[n], 'f', -1, 64)})
+	}
+	return tghtml.Table([]string{l.T("table.metric"), l.T("table.metric_value")}, rows)
+}
+
+// set — шаблоны одного языка
+type set struct {
+	active   *template.Template
+	fallback *template.Template // встроенные шаблоны, если active их заменяет
+}
+
+// Templates — наборы шаблонов уведомлений по языкам
+type Templates struct {
+	lang i18n.Lang // язык сервера
+	sets map[i18n.Lang]*set
+}
+
+// Default возвращает встроенные шаблоны с языком сервера по умолчанию
+func Default() *Templates {
+	t, err := Load("", i18n.Default)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Load возвращает встроенные шаблоны всех языков с языком сервера lang.
+// Файлы <событие>.tmpl из каталога dir заменяют шаблоны языка сервера,
+// а из подкаталогов dir/<язык> — шаблоны этого языка. Пустой dir — только
+// встроенные шаблоны. Каждый заменённый шаблон проверяется на примерах
+// данных, поэтому ошибки в именах полей и функций обнаруживаются при запуске.
+func Load(dir string, lang i18n.Lang) (*Templates, error) {
+	ret := &Templates{lang: lang, sets: make(map[i18n.Lang]*set)}
+	overrides := make(map[i18n.Lang]map[route.Event]string)
+	if dir != "" {
+		root, err := readDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		overrides[lang] = root
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if !e.IsDir() {
+				continue
+			}
+			l, err := i18n.Parse(e.Name())
+			if err != nil {
+				return nil, fmt.Errorf("templates %s: %w", e.Name(), err)
+			}
+			sub, err := readDir(filepath.Join(dir, e.Name()))
+			if err != nil {
+				return nil, err
+			}
+			// файлы подкаталога языка важнее общих
+			if overrides[l] == nil {
+				overrides[l] = sub
+			} else {
+				maps.Copy(overrides[l], sub)
+			}
+		}
+	}
+
+	for _, l := range i18n.Langs {
+		def, err := parse(l, nil)
+		if err != nil {
+			return nil, err
+		}
+		s := &set{active: def}
+		if len(overrides[l]) > 0 {
+			t, err := parse(l, overrides[l])
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", l, err)
+			}
+			s.active, s.fallback = t, def
+			if err := s.validate(); err != nil {
+				return nil, fmt.Errorf("%s: %w", l, err)
+			}
+		}
+		ret.sets[l] = s
+	}
+	return ret, nil
+}
+
+// readDir читает файлы <событие>.tmpl каталога dir
+func readDir(dir string) (map[route.Event]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[route.Event]string)
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ext)
+		if e.IsDir() || !ok {
+			continue
+		}
+		if !slices.Contains(Names, route.Event(name)) {
+			return nil, fmt.Errorf("template %s: unknown event %q", e.Name(), name)
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		ret[route.Event(name)] = string(data)
+	}
+	return ret, nil
+}
+
+// parse разбирает шаблоны всех событий языка l, беря текст из overrides
+// или встроенный
+func parse(l i18n.Lang, overrides map[route.Event]string) (*template.Template, error) {
+	root := template.New("").Funcs(funcs(l)).Option("missingkey=error")
+	for _, name := range Names {
+		text, ok := overrides[name]
+		if !ok {
+			data, err := defaults.ReadFile("templates/" + string(l) + "/" + string(name) + ext)
+			if err != nil {
+				return nil, err
+			}
+			text = string(data)
+		}
+		if _, err := root.New(string(name)).Parse(text); err != nil {
+			return nil, fmt.Errorf("template %s%s: %w", name, ext, err)
+		}
+	}
+	return root, nil
+}
+
+// validate выполняет шаблоны на всех примерах данных
+func (s *set) validate() error {
+	var errs []error
+	for _, name := range Names {
+		for _, data := range samples[name] {
+			if err := s.active.ExecuteTemplate(io.Discard, string(name), data); err != nil {
+				errs = append(errs, fmt.Errorf("template %s%s: %w", name, ext, err))
+				break
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Lang возвращает язык сервера
+func (t *Templates) Lang() i18n.Lang {
+	return t.lang
+}
+
+// Render оформляет уведомление о событии name на языке lang (пустой — язык
+// сервера) по данным data — значению типа, соответствующего событию
+// (Analysis, DeviceError и т.д.). Если заменённый шаблон не выполнился,
+// используется встроенный.
+func (t *Templates) Render(lang i18n.Lang, name route.Event, data any) (string, error) {
+	s, ok := t.sets[lang.Or(t.lang)]
+	if !ok {
+		return "", fmt.Errorf("no templates for language %q", lang)
+	}
+	text, err := execute(s.active, name, data)
+	if err != nil && s.fallback != nil {
+		slog.Error("template failed, using built-in", "template", name, "lang", lang, "err", err)
+		text, err = execute(s.fallback, name, data)
+	}
+	return text, err
+}
+
+func execute(t *template.Template, name route.Event, data any) (string, error) {
+	var b strings.Builder
+	if err := t.ExecuteTemplate(&b, string(name), data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(b.String()), nil
+}
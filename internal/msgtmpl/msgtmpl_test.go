@@ -0,0 +1,132 @@
+package msgtmpl
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/covrom/smart-control/internal/alert"
+	"github.com/covrom/smart-control/internal/i18n"
+	"github.com/covrom/smart-control/internal/route"
+	"github.com/covrom/smart-control/internal/smartdata"
+	"github.com/covrom/smart-control/internal/store"
+)
+
+func TestDefault(t *testing.T) {
+	for l, s := range Default().sets {
+		if err := s.validate(); err != nil {
+			t.Errorf("%s: %v", l, err)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	ts := time.Date(2025, 1, 2, 3, 4, 0, 0, time.Local)
+	tmpl := Default()
+	for _, c := range []struct {
+		name route.Event
+		data any
+		want string
+	}{
+		{route.EventAnalysis, Analysis{Alert: alert.EventProblem, Level: store.LevelCritical, PrevLevel: store.LevelOK,
+			Host: "nas", OS: "linux", Device: "/dev/sda", MountPaths: []string{"/", "/home"}, Text: "<b>Итог</b>"},
+			"<b>🔴 Состояние диска: норма → критично</b>\n💻 Анализ для <b>nas</b> (linux)\n" +
+				"📀 Устройство: <code>/dev/sda</code>, точки монтирования:\n/\n/home\n\n<b>Итог</b>"},
+		{route.EventAnalysis, Analysis{Alert: alert.EventRecovered, Level: store.LevelOK, PrevLevel: store.LevelWarning,
+			Host: "nas", OS: "linux", Device: "/dev/sda", Metrics: smartdata.Metrics{Values: map[string]float64{"t": 1}}},
+			"<b>✅ Диск снова в норме</b> (было: предупреждение)\n💻 Анализ для <b>nas</b> (linux)\n" +
+				"📀 Устройство: <code>/dev/sda</code>, точки монтирования отсутствуют\n\n" +
+				"<blockquote expandable><pre>Показатель Значение\nt          1</pre></blockquote>"},
+		{route.EventReportError, ReportError{Host: "a<b>", OS: "linux", Error: "x & y"},
+			"❌ <b>Ошибка для a&lt;b&gt;</b> (linux)\n<pre>x &amp; y</pre>"},
+		{route.EventDeviceError, DeviceError{Host: "nas", OS: "linux", Device: "/dev/sda", Code: smartdata.ErrCodeSmartctl, Error: "exit status 2"},
+			"❌ <b>Ошибка для nas</b> (linux)\nУстройство: <code>/dev/sda</code>\nОшибка анализа диска smartctl\n<pre>exit status 2</pre>"},
+		{route.EventDeviceError, DeviceError{Host: "nas", OS: "linux", Device: "/dev/sda", Code: smartdata.ErrCodeAnalysis,
+			Metrics: smartdata.Metrics{Values: map[string]float64{"t": 1}}},
+			"❌ <b>Ошибка для nas</b> (linux)\nУстройство: <code>/dev/sda</code>\nАнализ недоступен: ни один поставщик LLM не ответил\n\n" +
+				"<blockquote expandable><pre>Показатель Значение\nt          1</pre></blockquote>"},
+		{route.EventDeviceChange, DeviceChange{Host: "nas", OS: "linux", Added: true, Device: "/dev/sdb", Model: "WDC", Serial: "WD-1"},
+			"<b>➕ Новый диск на nas</b> (linux)\n📀 /dev/sdb — WDC, S/N WD-1"},
+		{route.EventDigest, Digest{From: ts, To: ts, Hosts: 1, Devices: 2, OK: 1, Warning: 1,
+			Concerns: []DigestDevice{{Host: "nas", Device: "/dev/sda", Model: "WDC", Level: store.LevelWarning, Since: ts}},
+			Failed:   []string{"db"}},
+			"<b>📊 Сводка за 02.01.2025 03:04 — 02.01.2025 03:04</b>\nХостов: 1, дисков: 2\n✅ 1  ⚠️ 1  🔴 0  ❔ 0\n\n" +
+				"<b>Требуют внимания:</b>\n⚠️ nas /dev/sda — WDC (с 02.01.2025)\n\n<b>Ошибка сбора данных:</b> db"},
+	} {
+		got, err := tmpl.Render("", c.name, c.data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != c.want {
+			t.Errorf("%s:\ngot  %q\nwant %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestRenderLang(t *testing.T) {
+	tmpl := Default()
+	got, err := tmpl.Render(i18n.EN, route.EventAnalysis, Analysis{Alert: alert.EventProblem, Level: store.LevelCritical,
+		PrevLevel: store.LevelOK, Host: "nas", OS: "linux", Device: "/dev/sda"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "<b>🔴 Disk state: ok → critical</b>\n💻 Analysis for <b>nas</b> (linux)\n📀 Device: <code>/dev/sda</code>, no mount points"; got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+	got, _ = tmpl.Render(i18n.EN, route.EventReportError, ReportError{Host: "nas", OS: "linux", Code: smartdata.ErrCodeListDevices, Error: "boom"})
+	if want := "❌ <b>Error on nas</b> (linux)\nFailed to list disks\n<pre>boom</pre>"; got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, text string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("report_error.tmpl", "Report failed on {{.Host}}: {{.Error}}\n")
+	write("README.md", "not a template")
+	if err := os.Mkdir(filepath.Join(dir, "en"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	write("en/device_error.tmpl", "{{.Device}} failed on {{.Host}}")
+
+	tmpl, err := Load(dir, i18n.RU)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := tmpl.Render("", route.EventReportError, ReportError{Host: "nas", Error: "boom"})
+	if got != "Report failed on nas: boom" {
+		t.Errorf("override = %q", got)
+	}
+	// остальные события — встроенными шаблонами
+	if got, _ := tmpl.Render(i18n.RU, route.EventDeviceError, DeviceError{Host: "nas"}); !strings.HasPrefix(got, "❌ <b>Ошибка для nas</b>") {
+		t.Errorf("default = %q", got)
+	}
+	// файлы каталога без языка заменяют шаблоны только языка сервера
+	if got, _ := tmpl.Render(i18n.EN, route.EventReportError, ReportError{Host: "nas", Error: "boom"}); !strings.HasPrefix(got, "❌ <b>Error on nas</b>") {
+		t.Errorf("en default = %q", got)
+	}
+	if got, _ := tmpl.Render(i18n.EN, route.EventDeviceError, DeviceError{Host: "nas", Device: "sda"}); got != "sda failed on nas" {
+		t.Errorf("en override = %q", got)
+	}
+
+	for name, text := range map[string]string{
+		"digest.tmpl":       "{{.NoSuchField}}",
+		"analysis.tmpl":     "{{if .Host}}",
+		"missing_host.tmpl": "{{nosuchfunc .Host}}",
+		"unknown.tmpl":      "x",
+		"de/digest.tmpl":    "x",
+	} {
+		dir := t.TempDir()
+		os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755)
+		os.WriteFile(filepath.Join(dir, name), []byte(text), 0o644)
+		if _, err := Load(dir, i18n.RU); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/covrom/smart-control/internal/queue"
+)
+
+// Способы защиты соединения с SMTP-сервером
+const (
+	SecurityStartTLS = "starttls" // STARTTLS после подключения (порт 587), по умолчанию
+	SecurityTLS      = "tls"      // TLS с самого начала (порт 465)
+	SecurityNone     = "none"     // без шифрования, только для локального сервера
+)
+
+// emailTimeout ограничивает время одной отправки письма
+const emailTimeout = time.Minute
+
+// EmailConfig — настройки почтового канала
+type EmailConfig struct {
+	Name     string   `json:"name"`
+	Host     string   `json:"host"`
+	Port     int      `json:"port,omitempty"`     // по умолчанию 587, 465 для tls, 25 для none
+	Security string   `json:"security,omitempty"` // starttls, tls или none
+	Username string   `json:"username,omitempty"` // пусто — без авторизации
+	Password string   `json:"password,omitempty"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+}
+
+// Validate проверяет настройки
+func (c EmailConfig) Validate() error {
+	if c.Host == "" {
+		return fmt.Errorf("email %q: no host", c.Name)
+	}
+	switch c.Security {
+	case "", SecurityStartTLS, SecurityTLS, SecurityNone:
+	default:
+		return fmt.Errorf("email %q: unknown security %q", c.Name, c.Security)
+	}
+	if _, err := mail.ParseAddress(c.From); err != nil {
+		return fmt.Errorf("email %q: from: %w", c.Name, err)
+	}
+	if len(c.To) == 0 {
+		return fmt.Errorf("email %q: no recipients", c.Name)
+	}
+	for _, to := range c.To {
+		if _, err := mail.ParseAddress(to); err != nil {
+			return fmt.Errorf("email %q: to: %w", c.Name, err)
+		}
+	}
+	return nil
+}
+
+// Email отправляет уведомления письмами: HTML и простой текст, вложения
+// (график, вывод smartctl) — файлами
+type Email struct {
+	cfg       EmailConfig
+	tlsConfig *tls.Config
+}
+
+// NewEmail создаёт почтовый канал по проверенным настройкам
+func NewEmail(cfg EmailConfig) *Email {
+	if cfg.Security == "" {
+		cfg.Security = SecurityStartTLS
+	}
+	if cfg.Port == 0 {
+		switch cfg.Security {
+		case SecurityTLS:
+			cfg.Port = 465
+		case SecurityNone:
+			cfg.Port = 25
+		default:
+			cfg.Port = 587
+		}
+	}
+	return &Email{cfg: cfg, tlsConfig: &tls.Config{ServerName: cfg.Host}}
+}
+
+// Notify отправляет письмо всем получателям канала
+func (e *Email) Notify(ctx context.Context, n Notification) error {
+	msg, err := e.message(n, time.Now())
+	if err != nil {
+		return queue.Permanent(err)
+	}
+	err = e.send(ctx, msg)
+	var perr *textproto.Error
+	if errors.As(err, &perr) && perr.Code >= 500 {
+		// сервер окончательно отказал: повтор не поможет
+		return queue.Permanent(err)
+	}
+	return err
+}
+
+// message собирает письмо: multipart/alternative с текстом и HTML внутри
+// multipart/mixed с вложениями
+func (e *Email) message(n Notification, now time.Time) ([]byte, error) {
+	var body bytes.Buffer
+	alt := multipart.NewWriter(&body)
+	if err := writeQP(alt, "text/plain; charset=utf-8", n.Text()); err != nil {
+		return nil, err
+	}
+	if err := writeQP(alt, "text/html; charset=utf-8", emailHTML(n.HTML)); err != nil {
+		return nil, err
+	}
+	if err := alt.Close(); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	mixed := multipart.NewWriter(&buf)
+
+	// Message-ID производен от ключа уведомления: повторная доставка того же
+	// уведомления даёт то же письмо, и почтовые клиенты склеивают дубли
+	sum := sha256.Sum256([]byte(n.Key))
+	from, err := mail.ParseAddress(e.cfg.From)
+	if err != nil {
+		return nil, err
+	}
+	_, domain, _ := strings.Cut(from.Address, "@")
+	var to []string
+	for _, s := range e.cfg.To {
+		addr, err := mail.ParseAddress(s)
+		if err != nil {
+			return nil, err
+		}
+		to = append(to, addr.String())
+	}
+
+	header := []struct{ k, v string }{
+		{"From", from.String()},
+		{"To", strings.Join(to, ", ")},
+		{"Subject", mime.QEncoding.Encode("utf-8", "[smart-control] "+n.Summary())},
+		{"Date", now.Format(time.RFC1123Z)},
+		{"Message-ID", "<" + hex.EncodeToString(sum[:16]) + "@" + domain + ">"},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "multipart/mixed; boundary=" + mixed.Boundary()},
+	}
+	var head bytes.Buffer
+	for _, h := range header {
+		fmt.Fprintf(&head, "%s: %s\r\n", h.k, h.v)
+	}
+	head.WriteString("\r\n")
+
+	altPart, err := mixed.CreatePart(textproto.MIMEHeader{"Content-Type": {"multipart/alternative; boundary=" + alt.Boundary()}})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := altPart.Write(body.Bytes()); err != nil {
+		return nil, err
+	}
+
+	for _, a := range n.Attachments {
+		w, err := mixed.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {mime.FormatMediaType(a.ContentType, map[string]string{"name": a.Name})},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": a.Name})},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		enc := base64.StdEncoding.EncodeToString(a.Data)
+		for len(enc) > 76 {
+			fmt.Fprintf(w, "%s\r\n", enc[:76])
+			enc = enc[76:]
+		}
+		fmt.Fprintf(w, "%s\r\n", enc)
+	}
+	if err := mixed.Close(); err != nil {
+		return nil, err
+	}
+	return append(head.Bytes(), buf.Bytes()...), nil
+}
+
+func writeQP(w *multipart.Writer, contentType, body string) error {
+	part, err := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return err
+	}
+	qp := quotedprintable.NewWriter(part)
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// emailHTML оборачивает HTML Telegram в документ: переводы строк в нём
+// значимы, поэтому текст выводится с white-space: pre-wrap
+func emailHTML(html string) string {
+	html = strings.ReplaceAll(html, "<blockquote expandable>", "<blockquote>")
+	return `<!DOCTYPE html><html><head><meta charset="utf-8"></head>` +
+		`<body><div style="font-family: sans-serif; white-space: pre-wrap">` + html + `</div></body></html>`
+}
+
+// send передаёт письмо SMTP-серверу
+func (e *Email) send(ctx context.Context, msg []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, emailTimeout)
+	defer cancel()
+
+	addr := net.JoinHostPort(e.cfg.Host, strconv.Itoa(e.cfg.Port))
+	var conn net.Conn
+	var err error
+	if e.cfg.Security == SecurityTLS {
+		conn, err = (&tls.Dialer{Config: e.tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("smtp dial: %w", err)
+	}
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+
+	c, err := smtp.NewClient(conn, e.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("smtp: %w", err)
+	}
+	defer c.Close()
+
+	if e.cfg.Security == SecurityStartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return queue.Permanent(errors.New("smtp: server does not support STARTTLS"))
+		}
+		if err := c.StartTLS(e.tlsConfig); err != nil {
+			return fmt.Errorf("smtp starttls: %w", err)
+		}
+	}
+	if e.cfg.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", e.cfg.Username, e.cfg.Password, e.cfg.Host)); err != nil {
+			return fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+
+	from, _ := mail.ParseAddress(e.cfg.From)
+	if err := c.Mail(from.Address); err != nil {
+		return fmt.Errorf("smtp mail from: %w", err)
+	}
+	for _, to := range e.cfg.To {
+		addr, _ := mail.ParseAddress(to)
+		if err := c.Rcpt(addr.Address); err != nil {
+			return fmt.Errorf("smtp rcpt %s: %w", addr.Address, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+	return c.Quit()
+}
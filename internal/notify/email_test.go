@@ -0,0 +1,261 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/covrom/smart-control/internal/route"
+	"github.com/covrom/smart-control/internal/store"
+)
+
+// received — письмо, принятое SMTP-заглушкой
+type received struct {
+	from, auth string
+	to         []string
+	tls        bool
+	data       string
+}
+
+// smtpStandIn — минимальный SMTP-сервер для тестов: принимает одно
+// соединение, поддерживает AUTH PLAIN и, если задан tlsConf, STARTTLS
+func smtpStandIn(t *testing.T, tlsConf *tls.Config) (port int, msgs <-chan received) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	ch := make(chan received, 1)
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var msg received
+		r, w := textproto.NewReader(bufio.NewReader(conn)), bufio.NewWriter(conn)
+		reply := func(s string) {
+			w.WriteString(s + "\r\n")
+			w.Flush()
+		}
+		reply("220 localhost ESMTP stand-in")
+		for {
+			line, err := r.ReadLine()
+			if err != nil {
+				return
+			}
+			cmd, arg, _ := strings.Cut(line, " ")
+			switch strings.ToUpper(cmd) {
+			case "EHLO", "HELO":
+				if tlsConf != nil && !msg.tls {
+					reply("250-localhost\r\n250-STARTTLS\r\n250 AUTH PLAIN")
+				} else {
+					reply("250-localhost\r\n250 AUTH PLAIN")
+				}
+			case "STARTTLS":
+				reply("220 ready")
+				tc := tls.Server(conn, tlsConf)
+				if err := tc.Handshake(); err != nil {
+					return
+				}
+				conn, msg.tls = tc, true
+				r, w = textproto.NewReader(bufio.NewReader(tc)), bufio.NewWriter(tc)
+			case "AUTH":
+				_, resp, _ := strings.Cut(arg, " ")
+				b, _ := base64.StdEncoding.DecodeString(resp)
+				msg.auth = string(b)
+				reply("235 ok")
+			case "MAIL":
+				msg.from = strings.Trim(strings.TrimPrefix(arg, "FROM:"), "<>")
+				reply("250 ok")
+			case "RCPT":
+				to := strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>")
+				if strings.HasPrefix(to, "reject") {
+					reply("550 no such user")
+					continue
+				}
+				msg.to = append(msg.to, to)
+				reply("250 ok")
+			case "DATA":
+				reply("354 go ahead")
+				data, err := r.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				msg.data = string(data)
+				reply("250 queued")
+				ch <- msg
+			case "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("502 not implemented")
+			}
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port, ch
+}
+
+var testNotification = Notification{
+	Key:    "report/1/sda",
+	Event:  route.EventAnalysis,
+	Host:   "nas",
+	Device: "/dev/sda",
+	Level:  store.LevelCritical,
+	HTML:   "<b>🔴 Состояние диска: норма → критично</b>\nПереназначено <code>24</code> сектора &amp; растёт",
+	Attachments: []Attachment{
+		{Name: "smartctl.txt", ContentType: "text/plain", Data: []byte("smartctl 7.4\n")},
+	},
+}
+
+func receive(t *testing.T, msgs <-chan received) received {
+	t.Helper()
+	select {
+	case m := <-msgs:
+		return m
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+	return received{}
+}
+
+func TestEmail_Notify(t *testing.T) {
+	port, msgs := smtpStandIn(t, nil)
+	e := NewEmail(EmailConfig{
+		Name: "ops", Host: "127.0.0.1", Port: port, Security: SecurityNone,
+		Username: "bot", Password: "secret",
+		From: "Smart Control <smart@example.com>", To: []string{"admin@example.com", "Дежурный <duty@example.com>"},
+	})
+	if err := e.Notify(context.Background(), testNotification); err != nil {
+		t.Fatal(err)
+	}
+	got := receive(t, msgs)
+
+	if got.from != "smart@example.com" || strings.Join(got.to, ",") != "admin@example.com,duty@example.com" {
+		t.Errorf("envelope from %q to %q", got.from, got.to)
+	}
+	if got.auth != "\x00bot\x00secret" {
+		t.Errorf("auth = %q", got.auth)
+	}
+
+	m, err := mail.ReadMessage(strings.NewReader(got.data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(m.Header.Get("Subject"))
+	if subject != "[smart-control] 🔴 Состояние диска: норма → критично" {
+		t.Errorf("subject = %q", subject)
+	}
+
+	// multipart/mixed: alternative (plain + html) и вложение
+	_, params, _ := mime.ParseMediaType(m.Header.Get("Content-Type"))
+	mr := multipart.NewReader(m.Body, params["boundary"])
+	alt, err := mr.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, altParams, _ := mime.ParseMediaType(alt.Header.Get("Content-Type"))
+	ar := multipart.NewReader(alt, altParams["boundary"])
+	var bodies []string
+	for {
+		p, err := ar.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(p) // quoted-printable декодируется читателем
+		bodies = append(bodies, p.Header.Get("Content-Type")+": "+string(b))
+	}
+	if len(bodies) != 2 ||
+		!strings.Contains(bodies[0], "text/plain") || !strings.Contains(bodies[0], "Переназначено 24 сектора & растёт") ||
+		!strings.Contains(bodies[1], "text/html") || !strings.Contains(bodies[1], "<code>24</code> сектора &amp; растёт") {
+		t.Errorf("bodies = %q", bodies)
+	}
+
+	att, err := mr.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(base64.NewDecoder(base64.StdEncoding, att))
+	if att.FileName() != "smartctl.txt" || string(data) != "smartctl 7.4\n" {
+		t.Errorf("attachment %q = %q", att.FileName(), data)
+	}
+}
+
+func TestEmail_StartTLS(t *testing.T) {
+	cert, pool := testCert(t)
+	port, msgs := smtpStandIn(t, &tls.Config{Certificates: []tls.Certificate{cert}})
+	e := NewEmail(EmailConfig{
+		Name: "ops", Host: "127.0.0.1", Port: port,
+		Username: "bot", Password: "secret",
+		From: "smart@example.com", To: []string{"admin@example.com"},
+	})
+	e.tlsConfig.RootCAs = pool
+	if err := e.Notify(context.Background(), testNotification); err != nil {
+		t.Fatal(err)
+	}
+	if got := receive(t, msgs); !got.tls || got.auth != "\x00bot\x00secret" {
+		t.Errorf("tls = %t, auth = %q", got.tls, got.auth)
+	}
+}
+
+func TestEmail_rejected(t *testing.T) {
+	port, _ := smtpStandIn(t, nil)
+	e := NewEmail(EmailConfig{
+		Name: "ops", Host: "127.0.0.1", Port: port, Security: SecurityNone,
+		From: "smart@example.com", To: []string{"reject@example.com"},
+	})
+	err := e.Notify(context.Background(), testNotification)
+	if err == nil || !strings.Contains(err.Error(), "550") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+// testCert — самоподписанный сертификат для 127.0.0.1
+func testCert(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pool
+}
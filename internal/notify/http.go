@@ -0,0 +1,51 @@
+package notify
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/covrom/smart-control/internal/queue"
+)
+
+// httpTimeout ограничивает время одного запроса к HTTP-сервисам каналов
+const httpTimeout = 30 * time.Second
+
+// maxResponse ограничивает размер читаемого ответа сервиса
+const maxResponse = 1 << 20
+
+// readResponse читает ответ сервиса и переводит код ошибки в ошибку
+// для очереди доставки
+func readResponse(resp *http.Response) ([]byte, error) {
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponse))
+	if resp.StatusCode >= 300 {
+		return body, statusError(resp, body)
+	}
+	return body, err
+}
+
+// statusError — ошибка по коду ответа. Ответы 4xx, кроме 408 и 429, означают,
+// что запрос не будет принят и повторять его бессмысленно; 429 с заголовком
+// Retry-After откладывает повтор на указанный срок.
+func statusError(resp *http.Response, body []byte) error {
+	msg := string(body)
+	if len(msg) > 512 {
+		msg = msg[:512]
+	}
+	err := fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(msg))
+	switch code := resp.StatusCode; {
+	case code == http.StatusTooManyRequests:
+		if sec, perr := strconv.Atoi(resp.Header.Get("Retry-After")); perr == nil && sec > 0 {
+			return queue.RetryAfter(err, time.Duration(sec)*time.Second)
+		}
+		return err
+	case code >= 400 && code < 500 && code != http.StatusRequestTimeout:
+		return queue.Permanent(err)
+	default:
+		return err
+	}
+}
@@ -0,0 +1,191 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/covrom/smart-control/internal/queue"
+)
+
+// MatrixConfig — настройки канала Matrix. HostRooms задаёт комнаты по
+// шаблону имени хоста (синтаксис path.Match); хосты без совпадения и
+// сводка попадают в Room.
+type MatrixConfig struct {
+	Name        string            `json:"name"`
+	Homeserver  string            `json:"homeserver"` // например https://matrix.example.com
+	AccessToken string            `json:"access_token"`
+	Room        string            `json:"room"` // идентификатор комнаты вида !id:server
+	HostRooms   map[string]string `json:"host_rooms,omitempty"`
+}
+
+// Validate проверяет настройки
+func (c MatrixConfig) Validate() error {
+	u, err := url.Parse(c.Homeserver)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("matrix %q: invalid homeserver %q", c.Name, c.Homeserver)
+	}
+	if c.AccessToken == "" {
+		return fmt.Errorf("matrix %q: no access_token", c.Name)
+	}
+	if !strings.HasPrefix(c.Room, "!") {
+		return fmt.Errorf("matrix %q: room must be a room id (!id:server), got %q", c.Name, c.Room)
+	}
+	for p, room := range c.HostRooms {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("matrix %q: invalid host pattern %q: %w", c.Name, p, err)
+		}
+		if !strings.HasPrefix(room, "!") {
+			return fmt.Errorf("matrix %q: room must be a room id (!id:server), got %q", c.Name, room)
+		}
+	}
+	return nil
+}
+
+// Matrix отправляет уведомления в комнаты Matrix через client-server API
+type Matrix struct {
+	cfg    MatrixConfig
+	client *http.Client
+}
+
+// NewMatrix создаёт канал по проверенным настройкам
+func NewMatrix(cfg MatrixConfig) *Matrix {
+	cfg.Homeserver = strings.TrimRight(cfg.Homeserver, "/")
+	return &Matrix{cfg: cfg, client: &http.Client{Timeout: httpTimeout}}
+}
+
+// room возвращает комнату для хоста
+func (m *Matrix) room(host string) string {
+	// при нескольких совпадениях выбираем шаблон, первый по алфавиту, как
+	// для тем форума в правилах маршрутизации
+	room, best := m.cfg.Room, ""
+	for p, r := range m.cfg.HostRooms {
+		if ok, _ := path.Match(p, host); ok && (best == "" || p < best) {
+			best, room = p, r
+		}
+	}
+	return room
+}
+
+// Notify отправляет текст уведомления и изображение, если оно есть.
+// Идентификаторы транзакций выводятся из ключа уведомления, поэтому при
+// повторе сервер не создаёт второе сообщение.
+func (m *Matrix) Notify(ctx context.Context, n Notification) error {
+	room := m.room(n.Host)
+	err := m.send(ctx, room, txnID(n.Key, "text"), map[string]any{
+		"msgtype":        "m.notice",
+		"body":           n.Text(),
+		"format":         "org.matrix.custom.html",
+		"formatted_body": matrixHTML(n.HTML),
+	})
+	if err != nil {
+		return err
+	}
+
+	img, ok := n.Image()
+	if !ok {
+		return nil
+	}
+	uri, err := m.upload(ctx, img)
+	if err != nil {
+		return err
+	}
+	return m.send(ctx, room, txnID(n.Key, "image"), map[string]any{
+		"msgtype": "m.image",
+		"body":    img.Name,
+		"url":     uri,
+		"info":    map[string]any{"mimetype": img.ContentType, "size": len(img.Data)},
+	})
+}
+
+// send отправляет событие m.room.message в комнату
+func (m *Matrix) send(ctx context.Context, room, txn string, content map[string]any) error {
+	body, err := json.Marshal(content)
+	if err != nil {
+		return queue.Permanent(err)
+	}
+	u := m.cfg.Homeserver + "/_matrix/client/v3/rooms/" + url.PathEscape(room) +
+		"/send/m.room.message/" + url.PathEscape(txn)
+	_, err = m.do(ctx, http.MethodPut, u, "application/json", body)
+	return err
+}
+
+// upload загружает вложение в медиарепозиторий и возвращает его mxc://-адрес
+func (m *Matrix) upload(ctx context.Context, a Attachment) (string, error) {
+	u := m.cfg.Homeserver + "/_matrix/media/v3/upload?filename=" + url.QueryEscape(a.Name)
+	resp, err := m.do(ctx, http.MethodPost, u, a.ContentType, a.Data)
+	if err != nil {
+		return "", err
+	}
+	var ret struct {
+		ContentURI string `json:"content_uri"`
+	}
+	if err := json.Unmarshal(resp, &ret); err != nil || ret.ContentURI == "" {
+		return "", fmt.Errorf("matrix upload: unexpected response %q", resp)
+	}
+	return ret.ContentURI, nil
+}
+
+func (m *Matrix) do(ctx context.Context, method, u, contentType string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, queue.Permanent(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+m.cfg.AccessToken)
+	req.Header.Set("Content-Type", contentType)
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	data, err := readResponse(resp)
+	if err != nil {
+		// ограничение частоты Matrix сообщает в теле ответа
+		var limit struct {
+			RetryAfterMs int64 `json:"retry_after_ms"`
+		}
+		if resp.StatusCode == http.StatusTooManyRequests &&
+			json.Unmarshal(data, &limit) == nil && limit.RetryAfterMs > 0 {
+			err = queue.RetryAfter(err, time.Duration(limit.RetryAfterMs)*time.Millisecond)
+		}
+		return nil, fmt.Errorf("matrix: %w", err)
+	}
+	return data, nil
+}
+
+// txnID — идентификатор транзакции части уведомления
+func txnID(key, part string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "smart-" + hex.EncodeToString(sum[:12]) + "-" + part
+}
+
+// matrixHTML переводит HTML Telegram в HTML сообщений Matrix: переносы
+// строк вне блоков кода становятся <br>, свёрнутые цитаты — обычными
+func matrixHTML(html string) string {
+	html = strings.ReplaceAll(html, "<blockquote expandable>", "<blockquote>")
+	var b strings.Builder
+	for html != "" {
+		i := strings.Index(html, "<pre")
+		if i < 0 {
+			i = len(html)
+		}
+		b.WriteString(strings.ReplaceAll(html[:i], "\n", "<br>"))
+		html = html[i:]
+		j := strings.Index(html, "</pre>")
+		if j < 0 {
+			b.WriteString(html)
+			break
+		}
+		j += len("</pre>")
+		b.WriteString(html[:j])
+		html = html[j:]
+	}
+	return b.String()
+}
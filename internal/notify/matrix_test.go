@@ -0,0 +1,92 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/covrom/smart-control/internal/queue"
+)
+
+func TestMatrix_Notify(t *testing.T) {
+	type event struct {
+		path    string
+		content map[string]any
+	}
+	var events []event
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			http.Error(w, `{"errcode":"M_UNKNOWN_TOKEN"}`, http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path == "/_matrix/media/v3/upload" {
+			io.WriteString(w, `{"content_uri": "mxc://example.com/chart"}`)
+			return
+		}
+		var c map[string]any
+		json.NewDecoder(r.Body).Decode(&c)
+		events = append(events, event{r.URL.EscapedPath(), c})
+		io.WriteString(w, `{"event_id": "$1"}`)
+	}))
+	defer srv.Close()
+
+	cfg := MatrixConfig{
+		Name: "matrix", Homeserver: srv.URL + "/", AccessToken: "tok",
+		Room: "!ops:example.com", HostRooms: map[string]string{"nas*": "!storage:example.com"},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	n := testNotification
+	n.Attachments = append(n.Attachments, Attachment{Name: "trend.png", ContentType: "image/png", Data: []byte("png")})
+	if err := NewMatrix(cfg).Notify(context.Background(), n); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("events = %v", events)
+	}
+	text, image := events[0], events[1]
+	if !strings.HasPrefix(text.path, "/_matrix/client/v3/rooms/%21storage:example.com/send/m.room.message/smart-") {
+		t.Errorf("path = %s", text.path)
+	}
+	if text.content["formatted_body"] != "<b>🔴 Состояние диска: норма → критично</b><br>Переназначено <code>24</code> сектора &amp; растёт" ||
+		text.content["body"] != "🔴 Состояние диска: норма → критично\nПереназначено 24 сектора & растёт" {
+		t.Errorf("content = %v", text.content)
+	}
+	if image.content["msgtype"] != "m.image" || image.content["url"] != "mxc://example.com/chart" {
+		t.Errorf("image = %v", image.content)
+	}
+
+	// повтор уведомления использует те же идентификаторы транзакций
+	NewMatrix(cfg).Notify(context.Background(), n)
+	if events[2].path != text.path {
+		t.Errorf("txn changed: %s != %s", events[2].path, text.path)
+	}
+}
+
+func TestMatrix_rateLimit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		io.WriteString(w, `{"errcode": "M_LIMIT_EXCEEDED", "retry_after_ms": 1500}`)
+	}))
+	defer srv.Close()
+
+	err := NewMatrix(MatrixConfig{Name: "m", Homeserver: srv.URL, AccessToken: "tok", Room: "!r:x"}).
+		Notify(context.Background(), testNotification)
+	if err == nil || !strings.Contains(err.Error(), "429") || queue.IsPermanent(err) {
+		t.Fatalf("err = %v, want temporary 429", err)
+	}
+}
+
+func TestMatrixHTML(t *testing.T) {
+	got := matrixHTML("<b>a</b>\nb\n<pre>x\ny</pre>\n<blockquote expandable>c</blockquote>")
+	want := "<b>a</b><br>b<br><pre>x\ny</pre><br><blockquote>c</blockquote>"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
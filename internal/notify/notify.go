@@ -0,0 +1,121 @@
+// Package notify описывает уведомления сервера и каналы их доставки.
+// Получатель отчётов, контроль хостов и сводка публикуют структурированные
+// уведомления, а каналы (Telegram, почта и др.) оформляют их по-своему.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/covrom/smart-control/internal/i18n"
+	"github.com/covrom/smart-control/internal/queue"
+	"github.com/covrom/smart-control/internal/route"
+	"github.com/covrom/smart-control/internal/store"
+	"github.com/covrom/smart-control/internal/tghtml"
+)
+
+// Attachment — вложение уведомления
+type Attachment struct {
+	Name        string `json:"name"`
+	ContentType string `json:"content_type"`
+	Data        []byte `json:"data"`
+}
+
+// Notification — уведомление о событии
+type Notification struct {
+	// Key — ключ идемпотентности: повторная публикация с тем же ключом
+	// не приводит к повторной доставке
+	Key    string      `json:"key"`
+	Event  route.Event `json:"event"`
+	Host   string      `json:"host,omitempty"`
+	Labels []string    `json:"labels,omitempty"`
+	Device string      `json:"device,omitempty"`
+	Level  store.Level `json:"level"`
+	// Title — краткая тема; пустая — первая строка текста
+	Title string `json:"title,omitempty"`
+	// HTML — текст в разметке HTML Telegram (см. пакет tghtml)
+	HTML string `json:"html"`
+	// Translations — HTML на других языках для получателей с языком,
+	// отличным от языка сервера (см. In)
+	Translations map[i18n.Lang]string `json:"translations,omitempty"`
+	// Buttons — добавить кнопки управления оповещениями устройства
+	// (в каналах, которые их поддерживают)
+	Buttons bool `json:"buttons,omitempty"`
+	// Muted — оповещения по устройству отключены: среди кнопок — включение
+	Muted       bool         `json:"muted,omitempty"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+}
+
+// Subject — сведения уведомления для правил маршрутизации
+func (n Notification) Subject() route.Subject {
+	return route.Subject{Event: n.Event, Host: n.Host, Labels: n.Labels, Level: n.Level}
+}
+
+// In возвращает уведомление с текстом на языке l, если для него есть
+// перевод, и без остальных переводов
+func (n Notification) In(l i18n.Lang) Notification {
+	if html, ok := n.Translations[l]; ok {
+		n.HTML = html
+	}
+	n.Translations = nil
+	return n
+}
+
+// Text — текст уведомления без разметки
+func (n Notification) Text() string {
+	return tghtml.Plain(n.HTML)
+}
+
+// Summary — тема уведомления: Title или первая непустая строка текста
+func (n Notification) Summary() string {
+	if n.Title != "" {
+		return n.Title
+	}
+	for _, line := range strings.Split(n.Text(), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return string(n.Event)
+}
+
+// Image возвращает первое вложение-изображение
+func (n Notification) Image() (Attachment, bool) {
+	for _, a := range n.Attachments {
+		if strings.HasPrefix(a.ContentType, "image/") {
+			return a, true
+		}
+	}
+	return Attachment{}, false
+}
+
+// Notifier — канал доставки уведомлений. Ошибка означает, что уведомление
+// не доставлено и его стоит повторить.
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// Job — задача очереди доставки: уведомление для канала с именем Notifier
+type Job struct {
+	Notifier     string       `json:"notifier"`
+	Notification Notification `json:"notification"`
+}
+
+// Handler доставляет задачи очереди через каналы по именам
+func Handler(notifiers map[string]Notifier) queue.Handler {
+	return func(ctx context.Context, job queue.Job) error {
+		var j Job
+		if err := job.Decode(&j); err != nil {
+			return queue.Permanent(err)
+		}
+		n, ok := notifiers[j.Notifier]
+		if !ok {
+			return queue.Permanent(fmt.Errorf("unknown notifier %q", j.Notifier))
+		}
+		if err := n.Notify(ctx, j.Notification); err != nil {
+			return fmt.Errorf("%s: %w", j.Notifier, err)
+		}
+		return nil
+	}
+}
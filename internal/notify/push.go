@@ -0,0 +1,174 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/covrom/smart-control/internal/queue"
+	"github.com/covrom/smart-control/internal/store"
+)
+
+// Сервисы push-уведомлений
+const (
+	PushNtfy   = "ntfy"
+	PushGotify = "gotify"
+)
+
+// PushConfig — настройки канала push-уведомлений ntfy или Gotify.
+// Приоритет сообщения определяется уровнем уведомления; Priorities
+// переопределяет значения по умолчанию.
+type PushConfig struct {
+	Name    string `json:"name"`
+	Service string `json:"service,omitempty"` // ntfy (по умолчанию) или gotify
+	URL     string `json:"url,omitempty"`     // адрес сервера; для ntfy по умолчанию https://ntfy.sh
+	Topic   string `json:"topic,omitempty"`   // тема ntfy
+	// Token — токен доступа ntfy или токен приложения Gotify
+	Token      string              `json:"token,omitempty"`
+	Priorities map[store.Level]int `json:"priorities,omitempty"`
+}
+
+// defaultPriorities — приоритеты по уровням: ntfy 1..5, Gotify 0..10
+var defaultPriorities = map[string]map[store.Level]int{
+	PushNtfy:   {store.LevelUnknown: 3, store.LevelOK: 2, store.LevelWarning: 4, store.LevelCritical: 5},
+	PushGotify: {store.LevelUnknown: 5, store.LevelOK: 2, store.LevelWarning: 6, store.LevelCritical: 9},
+}
+
+// ntfyTags — значки ntfy (коды emoji) по уровням
+var ntfyTags = map[store.Level]string{
+	store.LevelUnknown:  "grey_question",
+	store.LevelOK:       "white_check_mark",
+	store.LevelWarning:  "warning",
+	store.LevelCritical: "rotating_light",
+}
+
+// Validate проверяет настройки
+func (c PushConfig) Validate() error {
+	switch c.Service {
+	case "", PushNtfy:
+		if c.Topic == "" {
+			return fmt.Errorf("push %q: no topic", c.Name)
+		}
+	case PushGotify:
+		if c.URL == "" || c.Token == "" {
+			return fmt.Errorf("push %q: gotify requires url and token", c.Name)
+		}
+	default:
+		return fmt.Errorf("push %q: unknown service %q", c.Name, c.Service)
+	}
+	if c.URL != "" {
+		u, err := url.Parse(c.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("push %q: invalid url %q", c.Name, c.URL)
+		}
+	}
+	for l := range c.Priorities {
+		if _, ok := ntfyTags[l]; !ok {
+			return fmt.Errorf("push %q: unknown level %q", c.Name, l)
+		}
+	}
+	return nil
+}
+
+// Push отправляет уведомления в тему ntfy или приложение Gotify
+type Push struct {
+	cfg    PushConfig
+	client *http.Client
+}
+
+// NewPush создаёт канал по проверенным настройкам
+func NewPush(cfg PushConfig) *Push {
+	if cfg.Service == "" {
+		cfg.Service = PushNtfy
+	}
+	if cfg.URL == "" {
+		cfg.URL = "https://ntfy.sh"
+	}
+	cfg.URL = strings.TrimRight(cfg.URL, "/")
+	return &Push{cfg: cfg, client: &http.Client{Timeout: httpTimeout}}
+}
+
+// priority — приоритет сообщения для уровня уведомления
+func (p *Push) priority(l store.Level) int {
+	if v, ok := p.cfg.Priorities[l]; ok {
+		return v
+	}
+	def := defaultPriorities[p.cfg.Service]
+	if v, ok := def[l]; ok {
+		return v
+	}
+	return def[store.LevelUnknown]
+}
+
+// Notify публикует тему и текст уведомления без разметки
+func (p *Push) Notify(ctx context.Context, n Notification) error {
+	var (
+		u   string
+		msg any
+	)
+	title, text := n.Summary(), pushText(n)
+	switch p.cfg.Service {
+	case PushGotify:
+		u = p.cfg.URL + "/message"
+		msg = map[string]any{"title": title, "message": text, "priority": p.priority(n.Level)}
+	default:
+		// публикация JSON в корень сервера: заголовки HTTP не подходят
+		// для заголовка сообщения не в ASCII
+		u = p.cfg.URL
+		tags := []string{ntfyTags[n.Level]}
+		if n.Host != "" {
+			tags = append(tags, n.Host)
+		}
+		msg = map[string]any{
+			"topic":    p.cfg.Topic,
+			"title":    title,
+			"message":  text,
+			"priority": p.priority(n.Level),
+			"tags":     tags,
+		}
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return queue.Permanent(err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return queue.Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.cfg.Token != "" {
+		if p.cfg.Service == PushGotify {
+			req.Header.Set("X-Gotify-Key", p.cfg.Token)
+		} else {
+			req.Header.Set("Authorization", "Bearer "+p.cfg.Token)
+		}
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	if _, err := readResponse(resp); err != nil {
+		return fmt.Errorf("%s: %w", p.cfg.Service, err)
+	}
+	return nil
+}
+
+// pushText — текст без строки темы, если тема взята из текста
+func pushText(n Notification) string {
+	text := strings.TrimSpace(n.Text())
+	if n.Title == "" {
+		first, rest, _ := strings.Cut(text, "\n")
+		if strings.TrimSpace(first) == n.Summary() {
+			text = strings.TrimSpace(rest)
+		}
+	}
+	if text == "" {
+		return n.Summary()
+	}
+	return text
+}
@@ -0,0 +1,55 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/covrom/smart-control/internal/store"
+)
+
+func TestPush_Notify(t *testing.T) {
+	var got map[string]any
+	var path string
+	var header http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, header = r.URL.Path, r.Header
+		got = nil
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	ntfy := PushConfig{Name: "phone", URL: srv.URL, Topic: "disks", Token: "tk"}
+	if err := ntfy.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewPush(ntfy).Notify(context.Background(), testNotification); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/" || header.Get("Authorization") != "Bearer tk" ||
+		got["topic"] != "disks" || got["priority"] != 5.0 ||
+		got["title"] != "🔴 Состояние диска: норма → критично" ||
+		got["message"] != "Переназначено 24 сектора & растёт" {
+		t.Errorf("ntfy %s %v", path, got)
+	}
+
+	gotify := PushConfig{Name: "gotify", Service: PushGotify, URL: srv.URL, Token: "app",
+		Priorities: map[store.Level]int{store.LevelCritical: 10}}
+	if err := gotify.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	n := testNotification
+	if err := NewPush(gotify).Notify(context.Background(), n); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/message" || header.Get("X-Gotify-Key") != "app" || got["priority"] != 10.0 {
+		t.Errorf("gotify %s %v", path, got)
+	}
+	n.Level = store.LevelWarning
+	NewPush(gotify).Notify(context.Background(), n)
+	if got["priority"] != 6.0 {
+		t.Errorf("warning priority = %v", got["priority"])
+	}
+}
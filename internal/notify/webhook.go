@@ -0,0 +1,197 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"text/template"
+	"time"
+
+	"github.com/covrom/smart-control/internal/queue"
+	"github.com/covrom/smart-control/internal/route"
+	"github.com/covrom/smart-control/internal/store"
+)
+
+const (
+	// webhookRetries — повторы внутри одной попытки очереди; дальше задача
+	// повторяется по политике очереди
+	webhookRetries = 3
+	webhookBackoff = 2 * time.Second
+)
+
+// defaultWebhookTemplate — тело запроса, если шаблон не задан
+const defaultWebhookTemplate = `{"key": {{json .Key}}, "event": {{json .Event}}, "host": {{json .Host}}, ` +
+	`"device": {{json .Device}}, "labels": {{json .Labels}}, "level": {{json .Level}}, ` +
+	`"title": {{json .Title}}, "text": {{json .Text}}, "time": {{json .Time}}}`
+
+// WebhookConfig — настройки канала HTTP-запросов
+type WebhookConfig struct {
+	Name    string            `json:"name"`
+	URL     string            `json:"url"`
+	Method  string            `json:"method,omitempty"` // по умолчанию POST
+	Headers map[string]string `json:"headers,omitempty"`
+	// Template — шаблон тела запроса (text/template); пустой — JSON со всеми полями
+	Template    string `json:"template,omitempty"`
+	ContentType string `json:"content_type,omitempty"` // по умолчанию application/json
+	// Secret — ключ подписи HMAC-SHA256 тела, передаётся в X-Signature-256
+	Secret string `json:"secret,omitempty"`
+	// Events и MinLevel дополнительно ограничивают уведомления канала
+	Events   []route.Event `json:"events,omitempty"`
+	MinLevel store.Level   `json:"min_level,omitempty"`
+}
+
+// WebhookData — данные шаблона тела запроса
+type WebhookData struct {
+	Key    string
+	Event  route.Event
+	Host   string
+	Device string
+	Labels []string
+	Level  store.Level
+	Title  string // тема уведомления
+	Text   string // текст без разметки
+	HTML   string // текст в разметке HTML Telegram
+	Time   time.Time
+}
+
+var webhookFuncs = template.FuncMap{
+	// json записывает значение литералом JSON, в том числе экранированной строкой
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+func (c WebhookConfig) template() (*template.Template, error) {
+	text := c.Template
+	if text == "" {
+		text = defaultWebhookTemplate
+	}
+	return template.New(c.Name).Funcs(webhookFuncs).Option("missingkey=error").Parse(text)
+}
+
+// Validate проверяет настройки и шаблон
+func (c WebhookConfig) Validate() error {
+	u, err := url.Parse(c.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("webhook %q: invalid url %q", c.Name, c.URL)
+	}
+	tmpl, err := c.template()
+	if err != nil {
+		return fmt.Errorf("webhook %q: %w", c.Name, err)
+	}
+	// шаблон проверяем и на выполнение, чтобы ошибки в именах полей
+	// обнаружились при запуске, а не при первом уведомлении
+	if err := tmpl.Execute(io.Discard, WebhookData{}); err != nil {
+		return fmt.Errorf("webhook %q: %w", c.Name, err)
+	}
+	for _, e := range c.Events {
+		if !slices.Contains(route.Events, e) {
+			return fmt.Errorf("webhook %q: unknown event %q", c.Name, e)
+		}
+	}
+	return nil
+}
+
+// Webhook отправляет уведомления HTTP-запросами с телом по шаблону
+type Webhook struct {
+	cfg    WebhookConfig
+	tmpl   *template.Template
+	client *http.Client
+}
+
+// NewWebhook создаёт канал по проверенным настройкам
+func NewWebhook(cfg WebhookConfig) *Webhook {
+	if cfg.Method == "" {
+		cfg.Method = http.MethodPost
+	}
+	if cfg.ContentType == "" {
+		cfg.ContentType = "application/json"
+	}
+	tmpl, _ := cfg.template()
+	return &Webhook{cfg: cfg, tmpl: tmpl, client: &http.Client{Timeout: httpTimeout}}
+}
+
+// Notify отправляет уведомление, если оно проходит фильтры канала.
+// Временные ошибки (сеть, 5xx, 429) повторяются с паузой, остальные ответы
+// 4xx означают, что запрос не будет принят и повторять его бессмысленно.
+func (w *Webhook) Notify(ctx context.Context, n Notification) error {
+	if len(w.cfg.Events) > 0 && !slices.Contains(w.cfg.Events, n.Event) {
+		return nil
+	}
+	if w.cfg.MinLevel != "" && n.Level.Rank() < w.cfg.MinLevel.Rank() {
+		return nil
+	}
+
+	var body bytes.Buffer
+	err := w.tmpl.Execute(&body, WebhookData{
+		Key:    n.Key,
+		Event:  n.Event,
+		Host:   n.Host,
+		Device: n.Device,
+		Labels: n.Labels,
+		Level:  n.Level,
+		Title:  n.Summary(),
+		Text:   n.Text(),
+		HTML:   n.HTML,
+		Time:   time.Now(),
+	})
+	if err != nil {
+		return queue.Permanent(fmt.Errorf("template: %w", err))
+	}
+
+	for attempt := 1; ; attempt++ {
+		err = w.post(ctx, n, body.Bytes())
+		// срок повтора, указанный получателем, выдерживает очередь
+		if err == nil || attempt >= webhookRetries || queue.IsPermanent(err) || queue.IsRetryAfter(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(webhookBackoff * time.Duration(attempt)):
+		}
+	}
+}
+
+func (w *Webhook) post(ctx context.Context, n Notification, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, w.cfg.Method, w.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return queue.Permanent(err)
+	}
+	req.Header.Set("Content-Type", w.cfg.ContentType)
+	req.Header.Set("User-Agent", "smart-control")
+	req.Header.Set("X-Smart-Control-Event", string(n.Event))
+	// по ключу получатель может отбросить повтор уже принятого уведомления
+	req.Header.Set("Idempotency-Key", n.Key)
+	if w.cfg.Secret != "" {
+		req.Header.Set("X-Signature-256", "sha256="+Sign(w.cfg.Secret, body))
+	}
+	for k, v := range w.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	if _, err := readResponse(resp); err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	return nil
+}
+
+// Sign — подпись HMAC-SHA256 тела запроса в шестнадцатеричном виде
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,134 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/covrom/smart-control/internal/queue"
+	"github.com/covrom/smart-control/internal/route"
+	"github.com/covrom/smart-control/internal/store"
+)
+
+func TestWebhook_Notify(t *testing.T) {
+	var calls atomic.Int32
+	var body []byte
+	var header http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// первая попытка — временная ошибка, вторая принимается
+		if calls.Add(1) == 1 {
+			http.Error(w, "try later", http.StatusServiceUnavailable)
+			return
+		}
+		body, _ = io.ReadAll(r.Body)
+		header = r.Header
+	}))
+	defer srv.Close()
+
+	cfg := WebhookConfig{
+		Name:     "slack",
+		URL:      srv.URL,
+		Template: `{"text": {{json (printf "%s: %s" .Host .Title)}}}`,
+		Headers:  map[string]string{"Authorization": "Bearer t"},
+		Secret:   "s3cret",
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewWebhook(cfg).Notify(context.Background(), testNotification); err != nil {
+		t.Fatal(err)
+	}
+
+	var got struct{ Text string }
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatalf("body %q: %v", body, err)
+	}
+	if got.Text != "nas: 🔴 Состояние диска: норма → критично" {
+		t.Errorf("text = %q", got.Text)
+	}
+	if header.Get("X-Signature-256") != "sha256="+Sign("s3cret", body) ||
+		header.Get("Authorization") != "Bearer t" || header.Get("Idempotency-Key") != testNotification.Key {
+		t.Errorf("headers = %v", header)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("calls = %d", calls.Load())
+	}
+}
+
+func TestWebhook_defaultTemplateAndFilters(t *testing.T) {
+	var calls atomic.Int32
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	w := NewWebhook(WebhookConfig{Name: "n8n", URL: srv.URL, Events: []route.Event{route.EventAnalysis}, MinLevel: store.LevelWarning})
+	for _, n := range []Notification{
+		{Key: "a", Event: route.EventDigest, Level: store.LevelCritical},
+		{Key: "b", Event: route.EventAnalysis, Level: store.LevelOK},
+	} {
+		if err := w.Notify(context.Background(), n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls.Load() != 0 {
+		t.Fatalf("filtered notifications sent: %d", calls.Load())
+	}
+
+	if err := w.Notify(context.Background(), testNotification); err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatalf("body %q: %v", body, err)
+	}
+	if got["host"] != "nas" || got["device"] != "/dev/sda" || got["level"] != "critical" || got["event"] != "analysis" {
+		t.Errorf("body = %s", body)
+	}
+}
+
+func TestWebhook_permanent(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad payload", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	err := NewWebhook(WebhookConfig{Name: "x", URL: srv.URL}).Notify(context.Background(), testNotification)
+	if !queue.IsPermanent(err) {
+		t.Fatalf("err = %v, want permanent", err)
+	}
+}
+
+func TestWebhook_retryAfter(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Retry-After", "30")
+		http.Error(w, "slow down", http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	err := NewWebhook(WebhookConfig{Name: "x", URL: srv.URL}).Notify(context.Background(), testNotification)
+	if !queue.IsRetryAfter(err) || calls.Load() != 1 {
+		t.Fatalf("err = %v after %d calls, want retry after one call", err, calls.Load())
+	}
+}
+
+func TestWebhookConfig_Validate(t *testing.T) {
+	for _, c := range []WebhookConfig{
+		{Name: "no url"},
+		{Name: "bad template", URL: "http://x", Template: "{{.Nope}}"},
+		{Name: "unclosed", URL: "http://x", Template: "{{json .Host"},
+		{Name: "bad event", URL: "http://x", Events: []route.Event{"nope"}},
+	} {
+		if c.Validate() == nil {
+			t.Errorf("%s: expected error", c.Name)
+		}
+	}
+}
@@ -0,0 +1,503 @@
+package queue
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Подкаталоги очереди: ожидающие задачи, «мёртвые» задачи и метки выполненных ключей
+const (
+	pendingDir = "pending"
+	deadDir    = "dead"
+	doneDir    = "done"
+)
+
+// pollInterval — как часто воркер перечитывает каталог, даже если его не будили
+// (задачи могут появиться из другого процесса, например, через CLI requeue)
+const pollInterval = 5 * time.Second
+
+// Job — задача в очереди, хранится на диске в виде json-файла
+type Job struct {
+	ID        string          `json:"id"`
+	Key       string          `json:"key"` // ключ идемпотентности
+	Payload   json.RawMessage `json:"payload"`
+	Attempts  int             `json:"attempts"`
+	Postponed int             `json:"postponed,omitempty"` // откладываний по RetryAfter, см. Policy.PostponesPerAttempt
+	CreatedAt time.Time       `json:"created_at"`
+	NextRunAt time.Time       `json:"next_run_at"`
+	LastError string          `json:"last_error,omitempty"`
+	FailedAt  time.Time       `json:"failed_at,omitzero"`
+	Progress  int             `json:"progress,omitempty"` // сохранённый обработчиком прогресс, см. SaveProgress
+}
+
+// Decode распаковывает полезную нагрузку задачи в v
+func (j Job) Decode(v any) error {
+	return json.Unmarshal(j.Payload, v)
+}
+
+// Policy — политика повторов для задач очереди
+type Policy struct {
+	MaxAttempts int           // после стольких неудач задача уходит в dead
+	MinBackoff  time.Duration // пауза после первой неудачи, далее удваивается
+	MaxBackoff  time.Duration // верхняя граница паузы
+	KeepDone    time.Duration // сколько хранить ключи выполненных задач для дедупликации
+	// PostponesPerAttempt — каждое такое по счёту откладывание по RetryAfter
+	// засчитывается как неудачная попытка, чтобы задача, которую получатель
+	// откладывает бесконечно, всё же ушла в dead; 0 — не засчитывать
+	PostponesPerAttempt int
+}
+
+// DefaultPolicy — политика по умолчанию
+var DefaultPolicy = Policy{
+	MaxAttempts: 8,
+	MinBackoff:  30 * time.Second,
+	MaxBackoff:  6 * time.Hour,
+	KeepDone:    30 * 24 * time.Hour,
+
+	PostponesPerAttempt: 20,
+}
+
+func (p Policy) backoff(attempts int) time.Duration {
+	d := p.MinBackoff
+	for i := 1; i < attempts && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, p.MaxBackoff)
+}
+
+// permanentError — ошибка, повтор после которой бессмыслен
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent помечает ошибку обработчика как постоянную: задача сразу уходит в dead
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent сообщает, помечена ли ошибка как постоянная
+func IsPermanent(err error) bool {
+	var perr *permanentError
+	return errors.As(err, &perr)
+}
+
+// retryAfterError — задача не может быть обработана раньше указанного срока
+type retryAfterError struct {
+	err   error
+	delay time.Duration
+}
+
+func (e *retryAfterError) Error() string { return e.err.Error() }
+func (e *retryAfterError) Unwrap() error { return e.err }
+
+// RetryAfter откладывает задачу на delay, не считая попытку неудачной:
+// например, когда получатель просит подождать из-за ограничения частоты.
+// Неудачной считается только каждая Policy.PostponesPerAttempt-я отсрочка.
+func RetryAfter(err error, delay time.Duration) error {
+	if err == nil {
+		return nil
+	}
+	return &retryAfterError{err: err, delay: delay}
+}
+
+// IsRetryAfter сообщает, указан ли в ошибке срок повтора (см. RetryAfter)
+func IsRetryAfter(err error) bool {
+	var rerr *retryAfterError
+	return errors.As(err, &rerr)
+}
+
+// Handler обрабатывает задачу. Ошибка приводит к повтору по политике очереди.
+type Handler func(ctx context.Context, job Job) error
+
+// Queue — очередь задач на диске с обработкой «хотя бы один раз».
+// Каждая задача — отдельный файл, запись атомарная (через переименование),
+// поэтому задачи переживают перезапуск и аварийное завершение процесса.
+type Queue struct {
+	name   string
+	dir    string
+	policy Policy
+	mu     sync.Mutex
+	wake   chan struct{}
+	onDead func(job Job)
+}
+
+// Open открывает (создаёт при необходимости) очередь в каталоге dir
+func Open(dir string, policy Policy) (*Queue, error) {
+	for _, sub := range []string{pendingDir, deadDir, doneDir} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			return nil, fmt.Errorf("create queue dir: %w", err)
+		}
+	}
+	q := &Queue{
+		name:   filepath.Base(dir),
+		dir:    dir,
+		policy: policy,
+		wake:   make(chan struct{}, 1),
+	}
+	q.pruneDone(time.Now())
+	return q, nil
+}
+
+// Name возвращает имя очереди (имя её каталога)
+func (q *Queue) Name() string {
+	return q.name
+}
+
+func keyHash(key string) string {
+	h := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(h[:8])
+}
+
+// Enqueue добавляет задачу с ключом идемпотентности key.
+// Если задача с таким ключом уже ожидает, лежит в dead или была выполнена
+// не позднее KeepDone назад, она не добавляется и возвращается false.
+func (q *Queue) Enqueue(key string, payload any) (bool, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return false, fmt.Errorf("marshal payload: %w", err)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	kh := keyHash(key)
+	if q.hasKey(kh) {
+		return false, nil
+	}
+
+	now := time.Now()
+	job := Job{
+		ID:        fmt.Sprintf("%020d-%s", now.UnixNano(), kh),
+		Key:       key,
+		Payload:   data,
+		CreatedAt: now,
+		NextRunAt: now,
+	}
+	if err := q.write(pendingDir, job); err != nil {
+		return false, err
+	}
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return true, nil
+}
+
+func (q *Queue) hasKey(kh string) bool {
+	if _, err := os.Stat(filepath.Join(q.dir, doneDir, kh)); err == nil {
+		return true
+	}
+	for _, sub := range []string{pendingDir, deadDir} {
+		if m, _ := filepath.Glob(filepath.Join(q.dir, sub, "*-"+kh+".json")); len(m) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// write атомарно сохраняет задачу в подкаталог sub
+func (q *Queue) write(sub string, job Job) error {
+	data, err := json.MarshalIndent(job, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal job: %w", err)
+	}
+	filename := filepath.Join(q.dir, sub, job.ID+".json")
+	tmp := filename + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("write job: %w", err)
+	}
+	if err := os.Rename(tmp, filename); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("rename job: %w", err)
+	}
+	return nil
+}
+
+func (q *Queue) read(sub, id string) (Job, error) {
+	var job Job
+	data, err := os.ReadFile(filepath.Join(q.dir, sub, id+".json"))
+	if err != nil {
+		return job, err
+	}
+	if err := json.Unmarshal(data, &job); err != nil {
+		return job, fmt.Errorf("unmarshal job %s: %w", id, err)
+	}
+	return job, nil
+}
+
+// list возвращает задачи подкаталога в порядке постановки в очередь
+func (q *Queue) list(sub string) ([]Job, error) {
+	entries, err := os.ReadDir(filepath.Join(q.dir, sub))
+	if err != nil {
+		return nil, err
+	}
+	var jobs []Job
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok || e.IsDir() {
+			continue
+		}
+		job, err := q.read(sub, id)
+		if err != nil {
+			slog.Error("queue: skip broken job", "queue", q.name, "id", id, "err", err)
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].ID < jobs[j].ID })
+	return jobs, nil
+}
+
+// Pending возвращает ожидающие задачи
+func (q *Queue) Pending() ([]Job, error) {
+	return q.list(pendingDir)
+}
+
+// Dead возвращает задачи, исчерпавшие попытки
+func (q *Queue) Dead() ([]Job, error) {
+	return q.list(deadDir)
+}
+
+// Len возвращает число ожидающих и «мёртвых» задач по именам файлов, не
+// читая их: задачи бывают с вложениями, а метрики запрашиваются часто
+func (q *Queue) Len() (pending, dead int, err error) {
+	if pending, err = q.count(pendingDir); err != nil {
+		return 0, 0, err
+	}
+	if dead, err = q.count(deadDir); err != nil {
+		return 0, 0, err
+	}
+	return pending, dead, nil
+}
+
+func (q *Queue) count(sub string) (int, error) {
+	entries, err := os.ReadDir(filepath.Join(q.dir, sub))
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".json") && !e.IsDir() {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Get ищет задачу по id среди ожидающих и «мёртвых»
+func (q *Queue) Get(id string) (Job, bool, error) {
+	for _, sub := range []string{pendingDir, deadDir} {
+		job, err := q.read(sub, id)
+		if err == nil {
+			return job, sub == deadDir, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return job, false, err
+		}
+	}
+	return Job{}, false, os.ErrNotExist
+}
+
+// OnDead задаёт функцию, вызываемую воркером для каждой задачи, ушедшей в dead
+func (q *Queue) OnDead(fn func(job Job)) {
+	q.onDead = fn
+}
+
+// SaveProgress сохраняет прогресс обработки ожидающей задачи: при повторе
+// обработчик получит его в Job.Progress и сможет продолжить с того же места
+func (q *Queue) SaveProgress(job Job, progress int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	cur, err := q.read(pendingDir, job.ID)
+	if err != nil {
+		return err
+	}
+	cur.Progress = progress
+	return q.write(pendingDir, cur)
+}
+
+// Requeue возвращает задачу из dead в очередь со сброшенным счётчиком попыток
+func (q *Queue) Requeue(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, err := q.read(deadDir, id)
+	if err != nil {
+		return err
+	}
+	job.Attempts, job.Postponed = 0, 0
+	job.NextRunAt = time.Now()
+	if err := q.write(pendingDir, job); err != nil {
+		return err
+	}
+	return os.Remove(filepath.Join(q.dir, deadDir, id+".json"))
+}
+
+// complete отмечает ключ задачи выполненным и удаляет задачу
+func (q *Queue) complete(job Job) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	kh := keyHash(job.Key)
+	if err := os.WriteFile(filepath.Join(q.dir, doneDir, kh), []byte(job.Key), 0644); err != nil {
+		return fmt.Errorf("write done marker: %w", err)
+	}
+	return os.Remove(filepath.Join(q.dir, pendingDir, job.ID+".json"))
+}
+
+// fail учитывает неудачную попытку: откладывает задачу или переносит её в dead.
+// Возвращает обновлённую задачу и признак переноса в dead.
+func (q *Queue) fail(job Job, herr error) (Job, bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	// прогресс мог быть сохранён обработчиком
+	if cur, err := q.read(pendingDir, job.ID); err == nil {
+		job.Progress = cur.Progress
+	}
+
+	now := time.Now()
+	job.LastError = herr.Error()
+	job.FailedAt = now
+
+	var rerr *retryAfterError
+	postponed := errors.As(herr, &rerr)
+	if postponed {
+		job.Postponed++
+		if n := q.policy.PostponesPerAttempt; n == 0 || job.Postponed%n != 0 {
+			job.NextRunAt = now.Add(rerr.delay)
+			slog.Warn("queue: job postponed", "queue", q.name, "id", job.ID, "at", job.NextRunAt, "err", herr)
+			return job, false, q.write(pendingDir, job)
+		}
+	}
+
+	job.Attempts++
+	var perr *permanentError
+	if errors.As(herr, &perr) || job.Attempts >= q.policy.MaxAttempts {
+		if err := q.write(deadDir, job); err != nil {
+			return job, false, err
+		}
+		slog.Error("queue: job is dead", "queue", q.name, "id", job.ID, "attempts", job.Attempts, "err", herr)
+		return job, true, os.Remove(filepath.Join(q.dir, pendingDir, job.ID+".json"))
+	}
+
+	delay := q.policy.backoff(job.Attempts)
+	if postponed {
+		// засчитанная отсрочка: срок, о котором просил получатель, всё равно выдерживаем
+		delay = max(delay, rerr.delay)
+	}
+	job.NextRunAt = now.Add(delay)
+	slog.Warn("queue: job failed, retry later", "queue", q.name, "id", job.ID, "attempts", job.Attempts, "at", job.NextRunAt, "err", herr)
+	return job, false, q.write(pendingDir, job)
+}
+
+// pruneDone удаляет устаревшие метки выполненных ключей
+func (q *Queue) pruneDone(now time.Time) {
+	entries, err := os.ReadDir(filepath.Join(q.dir, doneDir))
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		info, err := e.Info()
+		if err == nil && now.Sub(info.ModTime()) > q.policy.KeepDone {
+			os.Remove(filepath.Join(q.dir, doneDir, e.Name()))
+		}
+	}
+}
+
+// next возвращает первую готовую к обработке задачу и время, когда стоит
+// проверить очередь снова
+func (q *Queue) next(now time.Time) (Job, bool, time.Time) {
+	wakeAt := now.Add(pollInterval)
+	jobs, err := q.Pending()
+	if err != nil {
+		slog.Error("queue: list pending", "queue", q.name, "err", err)
+		return Job{}, false, wakeAt
+	}
+	for _, job := range jobs {
+		if !job.NextRunAt.After(now) {
+			return job, true, now
+		}
+		if job.NextRunAt.Before(wakeAt) {
+			wakeAt = job.NextRunAt
+		}
+	}
+	return Job{}, false, wakeAt
+}
+
+// Run последовательно обрабатывает задачи очереди до отмены ctx.
+// Задача удаляется только после успешной обработки, поэтому после аварийного
+// завершения она будет обработана повторно.
+func (q *Queue) Run(ctx context.Context, wg *sync.WaitGroup, h Handler) {
+	defer wg.Done()
+
+	slog.Info("queue worker started", "queue", q.name)
+
+	lastPrune := time.Now()
+	for {
+		now := time.Now()
+		if now.Sub(lastPrune) > time.Hour {
+			q.pruneDone(now)
+			lastPrune = now
+		}
+
+		job, ok, wakeAt := q.next(now)
+		if ok {
+			err := h(ctx, job)
+			if ctx.Err() != nil {
+				// прерваны остановкой: попытку не учитываем
+				return
+			}
+			if err != nil {
+				var dead bool
+				job, dead, err = q.fail(job, err)
+				if dead && err == nil && q.onDead != nil {
+					q.onDead(job)
+				}
+			} else {
+				err = q.complete(job)
+			}
+			if err != nil {
+				slog.Error("queue: update job", "queue", q.name, "id", job.ID, "err", err)
+			}
+			continue
+		}
+
+		timer := time.NewTimer(time.Until(wakeAt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-q.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// String используется CLI для краткого вывода задачи
+func (j Job) String() string {
+	s := j.ID + " attempts=" + strconv.Itoa(j.Attempts) + " key=" + j.Key
+	if j.LastError != "" {
+		s += " error=" + strconv.Quote(j.LastError)
+	}
+	return s
+}
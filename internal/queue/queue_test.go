@@ -0,0 +1,250 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testPolicy = Policy{
+	MaxAttempts: 3,
+	MinBackoff:  time.Millisecond,
+	MaxBackoff:  5 * time.Millisecond,
+	KeepDone:    time.Hour,
+}
+
+func TestQueue_EnqueueIdempotent(t *testing.T) {
+	q, err := Open(t.TempDir(), testPolicy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	added, err := q.Enqueue("host@1", "payload")
+	if err != nil || !added {
+		t.Fatalf("first Enqueue = %v, %v; want true, nil", added, err)
+	}
+	added, err = q.Enqueue("host@1", "payload")
+	if err != nil || added {
+		t.Fatalf("second Enqueue = %v, %v; want false, nil", added, err)
+	}
+
+	jobs, _ := q.Pending()
+	if len(jobs) != 1 {
+		t.Fatalf("pending = %d, want 1", len(jobs))
+	}
+
+	// выполненная задача тоже блокирует повтор ключа
+	if err := q.complete(jobs[0]); err != nil {
+		t.Fatal(err)
+	}
+	if added, _ := q.Enqueue("host@1", "payload"); added {
+		t.Fatal("Enqueue after complete added duplicate")
+	}
+}
+
+func TestQueue_SurvivesReopen(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Open(dir, testPolicy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Enqueue("a", map[string]string{"text": "hello"}); err != nil {
+		t.Fatal(err)
+	}
+
+	q, err = Open(dir, testPolicy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs, _ := q.Pending()
+	if len(jobs) != 1 {
+		t.Fatalf("pending after reopen = %d, want 1", len(jobs))
+	}
+	var v map[string]string
+	if err := jobs[0].Decode(&v); err != nil || v["text"] != "hello" {
+		t.Fatalf("Decode = %v, %v", v, err)
+	}
+}
+
+func runUntil(t *testing.T, q *Queue, h Handler, done func() bool) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go q.Run(ctx, wg, h)
+
+	for !done() {
+		if ctx.Err() != nil {
+			t.Fatal("timeout")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	wg.Wait()
+}
+
+func TestQueue_RetryAndDead(t *testing.T) {
+	q, err := Open(t.TempDir(), testPolicy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Enqueue("flaky", 1)
+	q.Enqueue("broken", 2)
+	q.Enqueue("permanent", 3)
+
+	var mu sync.Mutex
+	calls := map[string]int{}
+	h := func(ctx context.Context, job Job) error {
+		mu.Lock()
+		defer mu.Unlock()
+		calls[job.Key]++
+		switch job.Key {
+		case "flaky":
+			if calls[job.Key] < 2 {
+				return errors.New("temporary")
+			}
+			return nil
+		case "broken":
+			return errors.New("always")
+		default:
+			return Permanent(errors.New("bad payload"))
+		}
+	}
+	runUntil(t, q, h, func() bool {
+		jobs, _ := q.Pending()
+		return len(jobs) == 0
+	})
+
+	if calls["flaky"] != 2 || calls["broken"] != testPolicy.MaxAttempts || calls["permanent"] != 1 {
+		t.Fatalf("calls = %v", calls)
+	}
+	dead, _ := q.Dead()
+	if len(dead) != 2 {
+		t.Fatalf("dead = %d, want 2", len(dead))
+	}
+	if pending, ndead, err := q.Len(); err != nil || pending != 0 || ndead != 2 {
+		t.Fatalf("Len = %d, %d, %v, want 0, 2", pending, ndead, err)
+	}
+
+	// requeue возвращает задачу со сброшенными попытками
+	if err := q.Requeue(dead[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	jobs, _ := q.Pending()
+	if len(jobs) != 1 || jobs[0].Attempts != 0 {
+		t.Fatalf("pending after requeue = %+v", jobs)
+	}
+}
+
+func TestPolicy_backoff(t *testing.T) {
+	p := Policy{MinBackoff: time.Second, MaxBackoff: 10 * time.Second}
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{4, 8 * time.Second},
+		{10, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := p.backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestQueue_RetryAfterAndProgress(t *testing.T) {
+	q, err := Open(t.TempDir(), testPolicy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Enqueue("parts", 1)
+
+	var dead []string
+	q.OnDead(func(job Job) { dead = append(dead, job.Key) })
+
+	// обработчик отправляет «части» по одной, сохраняя прогресс; каждую вторую
+	// попытку получатель просит подождать — это не должно расходовать попытки
+	const parts = 5
+	var sent []int
+	calls := 0
+	h := func(ctx context.Context, job Job) error {
+		calls++
+		for i := job.Progress; i < parts; i++ {
+			if calls%2 == 1 {
+				return RetryAfter(errors.New("flood"), time.Millisecond)
+			}
+			sent = append(sent, i)
+			if err := q.SaveProgress(job, i+1); err != nil {
+				return err
+			}
+			calls++
+		}
+		return nil
+	}
+	runUntil(t, q, h, func() bool {
+		jobs, _ := q.Pending()
+		return len(jobs) == 0
+	})
+
+	for i, p := range sent {
+		if p != i {
+			t.Fatalf("parts sent out of order or twice: %v", sent)
+		}
+	}
+	if len(sent) != parts || len(dead) != 0 {
+		t.Fatalf("sent = %v, dead = %v", sent, dead)
+	}
+}
+
+func TestQueue_PostponedForever(t *testing.T) {
+	policy := testPolicy
+	policy.PostponesPerAttempt = 2
+	q, err := Open(t.TempDir(), policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Enqueue("flood", 1)
+
+	// получатель всегда просит подождать: каждая вторая отсрочка — попытка
+	var calls atomic.Int32
+	h := func(ctx context.Context, job Job) error {
+		calls.Add(1)
+		return RetryAfter(errors.New("flood"), time.Millisecond)
+	}
+	runUntil(t, q, h, func() bool {
+		jobs, _ := q.Pending()
+		return len(jobs) == 0
+	})
+
+	dead, _ := q.Dead()
+	if len(dead) != 1 || dead[0].Attempts != policy.MaxAttempts || calls.Load() != int32(policy.MaxAttempts*2) {
+		t.Fatalf("dead = %+v after %d calls", dead, calls.Load())
+	}
+}
+
+func TestQueue_OnDead(t *testing.T) {
+	q, err := Open(t.TempDir(), testPolicy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Enqueue("bad", 1)
+	var dead []Job
+	q.OnDead(func(job Job) { dead = append(dead, job) })
+
+	runUntil(t, q, func(ctx context.Context, job Job) error {
+		return Permanent(errors.New("chat not found"))
+	}, func() bool {
+		jobs, _ := q.Pending()
+		return len(jobs) == 0
+	})
+	if len(dead) != 1 || dead[0].Key != "bad" || dead[0].LastError != "chat not found" {
+		t.Fatalf("dead = %+v", dead)
+	}
+}
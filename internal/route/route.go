@@ -0,0 +1,220 @@
+// Package route выбирает получателей уведомления по правилам маршрутизации:
+// по имени и меткам хоста, уровню и типу события. Получатели — чаты Telegram
+// и дополнительные каналы (почта и др.) по именам.
+package route
+
+import (
+	"fmt"
+	"path"
+	"slices"
+
+	"github.com/covrom/smart-control/internal/i18n"
+	"github.com/covrom/smart-control/internal/store"
+)
+
+// Event — тип события уведомления
+type Event string
+
+const (
+	EventAnalysis     Event = "analysis"      // смена состояния диска по анализу
+	EventDeviceError  Event = "device_error"  // ошибка чтения данных диска
+	EventReportError  Event = "report_error"  // ошибка сбора отчёта агентом
+	EventMissingHost  Event = "missing_host"  // хост пропустил отчёт или вернулся
+	EventDeviceChange Event = "device_change" // диск пропал или появился
+	EventDigest       Event = "digest"        // сводка по всем хостам
+)
+
+// Events — все известные типы событий
+var Events = []Event{EventAnalysis, EventDeviceError, EventReportError, EventMissingHost, EventDeviceChange, EventDigest}
+
+// Subject — что известно об уведомлении для выбора получателей
+type Subject struct {
+	Event  Event
+	Host   string // пусто для сводки
+	Labels []string
+	Level  store.Level
+}
+
+// Destination — чат Telegram и, для форумов, тема (message_thread_id)
+type Destination struct {
+	ChatID   int64     `json:"chat_id"`
+	ThreadID int       `json:"thread_id,omitempty"`
+	Lang     i18n.Lang `json:"lang,omitempty"` // язык сообщений, пустой — язык сервера
+}
+
+// Same сообщает, что d и o — один и тот же чат и тема
+func (d Destination) Same(o Destination) bool {
+	return d.ChatID == o.ChatID && d.ThreadID == o.ThreadID
+}
+
+// Channel — дополнительный канал доставки и язык сообщений для него
+type Channel struct {
+	Name string
+	Lang i18n.Lang // пустой — язык сервера
+}
+
+// Target — получатель маршрута. HostThreads задаёт темы форума по шаблону
+// имени хоста; хосты без совпадения попадают в ThreadID.
+type Target struct {
+	ChatID      int64          `json:"chat_id"`
+	ThreadID    int            `json:"thread_id,omitempty"`
+	HostThreads map[string]int `json:"host_threads,omitempty"`
+}
+
+// Route — правило маршрутизации. Пустое условие не ограничивает выбор.
+type Route struct {
+	Name     string      `json:"name,omitempty"`
+	Hosts    []string    `json:"hosts,omitempty"`     // шаблоны имени хоста (синтаксис path.Match)
+	Labels   []string    `json:"labels,omitempty"`    // достаточно одной из меток хоста
+	MinLevel store.Level `json:"min_level,omitempty"` // минимальный уровень события
+	Events   []Event     `json:"events,omitempty"`
+	Targets  []Target    `json:"targets,omitempty"`
+	Notify   []string    `json:"notify,omitempty"`   // имена дополнительных каналов доставки
+	Language i18n.Lang   `json:"language,omitempty"` // язык сообщений получателям правила, по умолчанию язык сервера
+}
+
+// Validate проверяет правило
+func (r Route) Validate() error {
+	if len(r.Targets) == 0 && len(r.Notify) == 0 {
+		return fmt.Errorf("route %q: no targets", r.Name)
+	}
+	for _, t := range r.Targets {
+		if t.ChatID == 0 {
+			return fmt.Errorf("route %q: target without chat_id", r.Name)
+		}
+		for p := range t.HostThreads {
+			if _, err := path.Match(p, ""); err != nil {
+				return fmt.Errorf("route %q: invalid host pattern %q: %w", r.Name, p, err)
+			}
+		}
+	}
+	for _, p := range r.Hosts {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("route %q: invalid host pattern %q: %w", r.Name, p, err)
+		}
+	}
+	for _, e := range r.Events {
+		if !slices.Contains(Events, e) {
+			return fmt.Errorf("route %q: unknown event %q", r.Name, e)
+		}
+	}
+	if r.Language != "" {
+		if _, err := i18n.Parse(string(r.Language)); err != nil {
+			return fmt.Errorf("route %q: %w", r.Name, err)
+		}
+	}
+	switch r.MinLevel {
+	case "", store.LevelUnknown, store.LevelOK, store.LevelWarning, store.LevelCritical:
+	default:
+		return fmt.Errorf("route %q: unknown level %q", r.Name, r.MinLevel)
+	}
+	return nil
+}
+
+// Match проверяет, подходит ли уведомление под правило
+func (r Route) Match(s Subject) bool {
+	if len(r.Events) > 0 && !slices.Contains(r.Events, s.Event) {
+		return false
+	}
+	if r.MinLevel != "" && s.Level.Rank() < r.MinLevel.Rank() {
+		return false
+	}
+	if len(r.Hosts) > 0 && !matchAny(r.Hosts, s.Host) {
+		return false
+	}
+	if len(r.Labels) > 0 && !slices.ContainsFunc(s.Labels, func(l string) bool { return slices.Contains(r.Labels, l) }) {
+		return false
+	}
+	return true
+}
+
+func matchAny(patterns []string, s string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, s); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// destination возвращает чат и тему получателя для хоста
+func (t Target) destination(host string, lang i18n.Lang) Destination {
+	d := Destination{ChatID: t.ChatID, ThreadID: t.ThreadID, Lang: lang}
+	// при нескольких совпадениях выбираем шаблон, первый по алфавиту, чтобы
+	// результат не зависел от порядка обхода map
+	var best string
+	for p, thread := range t.HostThreads {
+		if ok, _ := path.Match(p, host); ok && (best == "" || p < best) {
+			best, d.ThreadID = p, thread
+		}
+	}
+	return d
+}
+
+// Resolve возвращает чаты уведомления: объединение получателей всех
+// подходящих правил без повторов, а если ни одно правило с чатами не
+// подошло — def. Язык чата, указанного в нескольких правилах, берётся из
+// первого из них.
+func Resolve(routes []Route, def []Destination, s Subject) []Destination {
+	var ret []Destination
+	for _, r := range routes {
+		if !r.Match(s) {
+			continue
+		}
+		for _, t := range r.Targets {
+			if d := t.destination(s.Host, r.Language); !slices.ContainsFunc(ret, d.Same) {
+				ret = append(ret, d)
+			}
+		}
+	}
+	if len(ret) == 0 {
+		return def
+	}
+	return ret
+}
+
+// Notifiers возвращает дополнительные каналы всех подходящих правил; язык
+// канала, указанного в нескольких правилах, берётся из первого из них
+func Notifiers(routes []Route, s Subject) []Channel {
+	var ret []Channel
+	for _, r := range routes {
+		if !r.Match(s) {
+			continue
+		}
+		for _, n := range r.Notify {
+			if !slices.ContainsFunc(ret, func(c Channel) bool { return c.Name == n }) {
+				ret = append(ret, Channel{Name: n, Lang: r.Language})
+			}
+		}
+	}
+	return ret
+}
+
+// ChatLang возвращает язык чата: из первого правила с этим чатом и заданным
+// языком, иначе def
+func ChatLang(routes []Route, chatID int64, def i18n.Lang) i18n.Lang {
+	for _, r := range routes {
+		if r.Language == "" {
+			continue
+		}
+		for _, t := range r.Targets {
+			if t.ChatID == chatID {
+				return r.Language
+			}
+		}
+	}
+	return def
+}
+
+// Chats возвращает все чаты, упомянутые в правилах
+func Chats(routes []Route) []int64 {
+	var ret []int64
+	for _, r := range routes {
+		for _, t := range r.Targets {
+			if !slices.Contains(ret, t.ChatID) {
+				ret = append(ret, t.ChatID)
+			}
+		}
+	}
+	return ret
+}
@@ -0,0 +1,81 @@
+package route
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/covrom/smart-control/internal/i18n"
+	"github.com/covrom/smart-control/internal/store"
+)
+
+func TestResolve(t *testing.T) {
+	routes := []Route{
+		{Name: "home", Labels: []string{"home"}, Targets: []Target{{ChatID: 1}}},
+		{Name: "office", Hosts: []string{"office-*"}, Targets: []Target{{ChatID: -100, HostThreads: map[string]int{"office-db*": 7}}}},
+		{Name: "noisy", MinLevel: store.LevelCritical, Events: []Event{EventAnalysis}, Targets: []Target{{ChatID: -100}, {ChatID: -200}}, Language: i18n.EN},
+	}
+	def := []Destination{{ChatID: 42}}
+
+	for _, tc := range []struct {
+		name string
+		s    Subject
+		want []Destination
+	}{
+		{"label", Subject{Event: EventAnalysis, Host: "nas", Labels: []string{"home"}, Level: store.LevelWarning},
+			[]Destination{{ChatID: 1}}},
+		{"host pattern with thread", Subject{Event: EventMissingHost, Host: "office-db1", Level: store.LevelWarning},
+			[]Destination{{ChatID: -100, ThreadID: 7}}},
+		{"host pattern without thread", Subject{Event: EventMissingHost, Host: "office-pc", Level: store.LevelWarning},
+			[]Destination{{ChatID: -100}}},
+		{"critical to several", Subject{Event: EventAnalysis, Host: "office-pc", Level: store.LevelCritical},
+			[]Destination{{ChatID: -100}, {ChatID: -200, Lang: i18n.EN}}},
+		{"critical wrong event", Subject{Event: EventDeviceError, Host: "x", Level: store.LevelCritical}, def},
+		{"no match", Subject{Event: EventDigest, Level: store.LevelOK}, def},
+	} {
+		if got := Resolve(routes, def, tc.s); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	bad := []Route{
+		{Name: "no targets"},
+		{Targets: []Target{{}}},
+		{Events: []Event{"nope"}, Targets: []Target{{ChatID: 1}}},
+		{MinLevel: "high", Targets: []Target{{ChatID: 1}}},
+		{Hosts: []string{"["}, Targets: []Target{{ChatID: 1}}},
+		{Language: "de", Targets: []Target{{ChatID: 1}}},
+	}
+	for i, r := range bad {
+		if r.Validate() == nil {
+			t.Errorf("route %d: expected error", i)
+		}
+	}
+}
+
+func TestNotifiers(t *testing.T) {
+	routes := []Route{
+		{Name: "mail", MinLevel: store.LevelCritical, Notify: []string{"mail"}},
+		{Name: "both", Labels: []string{"office"}, Targets: []Target{{ChatID: -100}}, Notify: []string{"mail", "hook"}, Language: i18n.EN},
+	}
+	s := Subject{Event: EventAnalysis, Host: "nas", Level: store.LevelCritical}
+	if got := Notifiers(routes, s); !reflect.DeepEqual(got, []Channel{{Name: "mail"}}) {
+		t.Errorf("got %v", got)
+	}
+	// правило без чатов не отменяет чаты по умолчанию
+	def := []Destination{{ChatID: 1}}
+	if got := Resolve(routes, def, s); !reflect.DeepEqual(got, def) {
+		t.Errorf("resolve: got %v", got)
+	}
+	s.Labels = []string{"office"}
+	if got := Notifiers(routes, s); !reflect.DeepEqual(got, []Channel{{Name: "mail"}, {Name: "hook", Lang: i18n.EN}}) {
+		t.Errorf("got %v", got)
+	}
+	if got := ChatLang(routes, -100, i18n.RU); got != i18n.EN {
+		t.Errorf("chat lang: got %v", got)
+	}
+	if got := ChatLang(routes, 1, i18n.RU); got != i18n.RU {
+		t.Errorf("default chat lang: got %v", got)
+	}
+}
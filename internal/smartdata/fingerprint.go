@@ -0,0 +1,71 @@
+package smartdata
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+)
+
+// healthValues — показатели, изменение которых означает изменение состояния
+// диска. Наработка, число включений и объёмы чтения и записи растут при
+// обычной работе и в отпечаток не входят.
+var healthValues = []string{
+	MetricReallocated,
+	MetricPending,
+	MetricOfflineUncorr,
+	MetricCRCErrors,
+	MetricPercentageUsed,
+	MetricAvailableSpare,
+	MetricMediaErrors,
+	MetricErrorLogEntries,
+	MetricCriticalWarning,
+	MetricGrownDefects,
+	MetricHealthPassed,
+	MetricCapacityBytes,
+}
+
+// healthAttributes — атрибуты ATA без отдельного показателя, рост сырых
+// значений которых говорит о неисправности: Spin_Retry_Count,
+// End-to-End_Error, Reported_Uncorrect, Command_Timeout, Reallocated_Event_Count
+var healthAttributes = []int{10, 184, 187, 188, 196}
+
+// temperatureStep — ширина диапазона температуры в отпечатке, °C: колебания
+// на градус-другой от ночи к ночи заключения не меняют
+const temperatureStep = 10
+
+// Fingerprint — отпечаток значимых для состояния диска данных: модели,
+// прошивки, общей оценки, счётчиков ошибок и износа, диапазона температуры
+// и атрибутов, достигших порога. Одинаковые отпечатки двух снимков означают,
+// что изменились только растущие при работе счётчики. Пустая строка — данных
+// о состоянии в выводе не нашлось и сравнивать нечего.
+func (m Metrics) Fingerprint() string {
+	if m.Health == "" && len(m.Attributes) == 0 && !slices.ContainsFunc(healthValues, func(name string) bool {
+		_, ok := m.Values[name]
+		return ok
+	}) {
+		return ""
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "model=%s\nserial=%s\nfirmware=%s\nhealth=%s\n", m.Model, m.Serial, m.Firmware, m.Health)
+	for _, name := range healthValues {
+		if v, ok := m.Values[name]; ok {
+			fmt.Fprintf(h, "%s=%s\n", name, strconv.FormatFloat(v, 'g', -1, 64))
+		}
+	}
+	if t, ok := m.Values[MetricTemperature]; ok {
+		fmt.Fprintf(h, "temperature_band=%d\n", int(math.Floor(t/temperatureStep)))
+	}
+	for _, a := range m.Attributes {
+		if a.WhenFailed != "" {
+			fmt.Fprintf(h, "failed %d=%s\n", a.ID, a.WhenFailed)
+		}
+		if slices.Contains(healthAttributes, a.ID) {
+			fmt.Fprintf(h, "attr %d=%s\n", a.ID, a.Raw)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,40 @@
+package smartdata
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFingerprint(t *testing.T) {
+	base := ParseSmartctl(ataSample).Fingerprint()
+	if base == "" {
+		t.Fatal("empty fingerprint")
+	}
+
+	// наработка, включения, запись и температура в пределах диапазона — не изменения
+	same := strings.NewReplacer(
+		"-       21034", "-       21058",
+		"-       150", "-       151",
+		"-       1000", "-       9000",
+		"-       35 (Min/Max 20/48)", "-       38 (Min/Max 20/48)",
+	).Replace(ataSample)
+	if got := ParseSmartctl(same).Fingerprint(); got != base {
+		t.Error("fingerprint changed by operational counters")
+	}
+
+	for name, changed := range map[string]string{
+		"reallocated": strings.Replace(ataSample, "-       3\n", "-       8\n", 1),
+		"temperature": strings.Replace(ataSample, "-       35 (Min", "-       52 (Min", 1),
+		"health":      strings.Replace(ataSample, "result: PASSED", "result: FAILED!", 1),
+		"error log":   strings.Replace(ataSample, "ATA Error Count: 2", "ATA Error Count: 3", 1),
+		"when failed": strings.Replace(ataSample, "Always       -       58", "Always   FAILING_NOW 58", 1),
+	} {
+		if got := ParseSmartctl(changed).Fingerprint(); got == base {
+			t.Errorf("%s: fingerprint not changed", name)
+		}
+	}
+
+	if got := ParseSmartctl("smartctl: device not found").Fingerprint(); got != "" {
+		t.Errorf("fingerprint without data = %q", got)
+	}
+}
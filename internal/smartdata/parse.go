@@ -0,0 +1,263 @@
+package smartdata
+
+import (
+	"bufio"
+	"strconv"
+	"strings"
+)
+
+// Имена показателей, извлекаемых из вывода smartctl -a.
+// Используются как ключи Metrics.Values и в рядах истории.
+const (
+	MetricTemperature      = "temperature_celsius"
+	MetricPowerOnHours     = "power_on_hours"
+	MetricPowerCycles      = "power_cycles"
+	MetricReallocated      = "reallocated_sectors"
+	MetricPending          = "pending_sectors"
+	MetricOfflineUncorr    = "offline_uncorrectable"
+	MetricCRCErrors        = "udma_crc_errors"
+	MetricPercentageUsed   = "percentage_used"
+	MetricAvailableSpare   = "available_spare"
+	MetricBytesWritten     = "bytes_written"
+	MetricBytesRead        = "bytes_read"
+	MetricMediaErrors      = "media_errors"
+	MetricErrorLogEntries  = "error_log_entries"
+	MetricUnsafeShutdowns  = "unsafe_shutdowns"
+	MetricCriticalWarning  = "critical_warning"
+	MetricGrownDefects     = "grown_defects"
+	MetricCapacityBytes    = "capacity_bytes"
+	MetricHealthPassed     = "health_passed" // 1 — PASSED/OK, 0 — FAILED
+	nvmeDataUnitBytes      = 512 * 1000
+	defaultLogicalSectorSz = 512
+)
+
+// Attribute — строка таблицы атрибутов ATA
+type Attribute struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	Value      int    `json:"value"`
+	Worst      int    `json:"worst"`
+	Thresh     int    `json:"thresh"`
+	WhenFailed string `json:"when_failed,omitempty"`
+	Raw        string `json:"raw"`
+}
+
+// Metrics — разобранные из вывода smartctl идентификационные данные и показатели
+type Metrics struct {
+	ModelFamily string             `json:"model_family,omitempty"`
+	Model       string             `json:"model,omitempty"`
+	Serial      string             `json:"serial,omitempty"`
+	Firmware    string             `json:"firmware,omitempty"`
+	Health      string             `json:"health,omitempty"` // PASSED, FAILED, OK...
+	Values      map[string]float64 `json:"values,omitempty"`
+	Attributes  []Attribute        `json:"attributes,omitempty"`
+}
+
+// Value возвращает показатель и признак его наличия
+func (m Metrics) Value(name string) (float64, bool) {
+	v, ok := m.Values[name]
+	return v, ok
+}
+
+// ParseSmartctl разбирает текстовый вывод smartctl -a (ATA, NVMe, SCSI).
+// Неизвестные строки пропускаются, поэтому частично распознанный вывод
+// даёт частично заполненные Metrics.
+func ParseSmartctl(text string) Metrics {
+	m := Metrics{Values: map[string]float64{}}
+	sectorSize := float64(defaultLogicalSectorSz)
+	inAttrs := false
+	vendor := ""
+
+	sc := bufio.NewScanner(strings.NewReader(text))
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := sc.Text()
+		trimmed := strings.TrimSpace(line)
+
+		if strings.HasPrefix(trimmed, "ID# ATTRIBUTE_NAME") {
+			inAttrs = true
+			continue
+		}
+		if inAttrs {
+			if trimmed == "" {
+				inAttrs = false
+				continue
+			}
+			if a, ok := parseAttribute(trimmed); ok {
+				m.Attributes = append(m.Attributes, a)
+			}
+			continue
+		}
+
+		key, val, ok := strings.Cut(trimmed, ":")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		val = strings.TrimSpace(val)
+
+		switch key {
+		case "Model Family":
+			m.ModelFamily = val
+		case "Device Model", "Model Number":
+			m.Model = val
+		case "Vendor":
+			vendor = val
+		case "Product":
+			// SCSI: модель складывается из Vendor + Product
+			m.Model = strings.TrimSpace(vendor + " " + val)
+		case "Serial Number", "Serial number":
+			m.Serial = val
+		case "Firmware Version", "Revision":
+			if m.Firmware == "" {
+				m.Firmware = val
+			}
+		case "User Capacity", "Total NVM Capacity", "Namespace 1 Size/Capacity":
+			if _, ok := m.Values[MetricCapacityBytes]; !ok {
+				if v, ok := leadingNumber(val); ok && v > 0 {
+					m.Values[MetricCapacityBytes] = v
+				}
+			}
+		case "Sector Size", "Sector Sizes":
+			// "512 bytes logical, 4096 bytes physical"
+			if v, ok := leadingNumber(val); ok && v > 0 {
+				sectorSize = v
+			}
+		case "SMART overall-health self-assessment test result", "SMART Health Status":
+			m.Health = strings.TrimRight(strings.Fields(val + " ")[0], "!")
+		case "Critical Warning":
+			if v, err := strconv.ParseInt(strings.TrimPrefix(val, "0x"), 16, 64); err == nil {
+				m.Values[MetricCriticalWarning] = float64(v)
+			}
+		case "Temperature", "Current Drive Temperature", "Current Temperature":
+			setNumber(m.Values, MetricTemperature, val)
+		case "Available Spare":
+			setNumber(m.Values, MetricAvailableSpare, val)
+		case "Percentage Used":
+			setNumber(m.Values, MetricPercentageUsed, val)
+		case "Data Units Written":
+			if v, ok := leadingNumber(val); ok {
+				m.Values[MetricBytesWritten] = v * nvmeDataUnitBytes
+			}
+		case "Data Units Read":
+			if v, ok := leadingNumber(val); ok {
+				m.Values[MetricBytesRead] = v * nvmeDataUnitBytes
+			}
+		case "Power Cycles":
+			setNumber(m.Values, MetricPowerCycles, val)
+		case "Power On Hours":
+			setNumber(m.Values, MetricPowerOnHours, val)
+		case "Accumulated power on time, hours":
+			// SCSI: "hours:minutes 1234:56" — ключ уже отрезан по первому ':'
+			f := strings.Fields(val)
+			if len(f) > 0 {
+				h, _, _ := strings.Cut(f[len(f)-1], ":")
+				setNumber(m.Values, MetricPowerOnHours, h)
+			}
+		case "Unsafe Shutdowns":
+			setNumber(m.Values, MetricUnsafeShutdowns, val)
+		case "Media and Data Integrity Errors":
+			setNumber(m.Values, MetricMediaErrors, val)
+		case "Error Information Log Entries", "ATA Error Count":
+			setNumber(m.Values, MetricErrorLogEntries, val)
+		case "Elements in grown defect list":
+			setNumber(m.Values, MetricGrownDefects, val)
+		}
+	}
+
+	if m.Health != "" {
+		m.Values[MetricHealthPassed] = 0
+		if m.Health == "PASSED" || m.Health == "OK" {
+			m.Values[MetricHealthPassed] = 1
+		}
+	}
+
+	for _, a := range m.Attributes {
+		raw, rawOK := leadingNumber(a.Raw)
+		switch a.ID {
+		case 5:
+			setRaw(m.Values, MetricReallocated, raw, rawOK)
+		case 9:
+			setRaw(m.Values, MetricPowerOnHours, raw, rawOK)
+		case 12:
+			setRaw(m.Values, MetricPowerCycles, raw, rawOK)
+		case 190, 194:
+			if _, ok := m.Values[MetricTemperature]; !ok || a.ID == 194 {
+				setRaw(m.Values, MetricTemperature, raw, rawOK)
+			}
+		case 197:
+			setRaw(m.Values, MetricPending, raw, rawOK)
+		case 198:
+			setRaw(m.Values, MetricOfflineUncorr, raw, rawOK)
+		case 199:
+			setRaw(m.Values, MetricCRCErrors, raw, rawOK)
+		case 241:
+			if rawOK && a.Name == "Total_LBAs_Written" {
+				m.Values[MetricBytesWritten] = raw * sectorSize
+			}
+		case 242:
+			if rawOK && a.Name == "Total_LBAs_Read" {
+				m.Values[MetricBytesRead] = raw * sectorSize
+			}
+		case 177, 231, 233:
+			// нормализованное значение ресурса SSD: 100 — новый, 0 — изношен
+			if _, ok := m.Values[MetricPercentageUsed]; !ok && a.Value > 0 && a.Value <= 100 {
+				m.Values[MetricPercentageUsed] = float64(100 - a.Value)
+			}
+		}
+	}
+
+	return m
+}
+
+// parseAttribute разбирает строку вида
+// "  5 Reallocated_Sector_Ct   0x0033   100   100   010    Pre-fail  Always       -       0"
+func parseAttribute(line string) (Attribute, bool) {
+	f := strings.Fields(line)
+	if len(f) < 10 {
+		return Attribute{}, false
+	}
+	id, err := strconv.Atoi(f[0])
+	if err != nil {
+		return Attribute{}, false
+	}
+	a := Attribute{ID: id, Name: f[1]}
+	a.Value, _ = strconv.Atoi(f[3])
+	a.Worst, _ = strconv.Atoi(f[4])
+	a.Thresh, _ = strconv.Atoi(f[5])
+	if f[8] != "-" {
+		a.WhenFailed = f[8]
+	}
+	a.Raw = strings.Join(f[9:], " ")
+	return a, true
+}
+
+// leadingNumber извлекает первое число из строки, допуская запятые-разделители разрядов:
+// "1,234,567 [632 GB]" → 1234567, "35 Celsius" → 35, "12345h+00m" → 12345
+func leadingNumber(s string) (float64, bool) {
+	s = strings.TrimSpace(s)
+	end := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != ',' && r != '.'
+	})
+	if end >= 0 {
+		s = s[:end]
+	}
+	s = strings.ReplaceAll(s, ",", "")
+	if s == "" {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	return v, err == nil
+}
+
+func setNumber(values map[string]float64, name, s string) {
+	if v, ok := leadingNumber(s); ok {
+		values[name] = v
+	}
+}
+
+func setRaw(values map[string]float64, name string, v float64, ok bool) {
+	if ok {
+		values[name] = v
+	}
+}
@@ -0,0 +1,122 @@
+package smartdata
+
+import (
+	"testing"
+)
+
+const ataSample = `smartctl 7.4 2023-08-01 r5530 [x86_64-linux-6.1.0] (local build)
+Copyright (C) 2002-23, Bruce Allen, Christian Franke, www.smartmontools.org
+
+=== START OF INFORMATION SECTION ===
+Model Family:     Samsung based SSDs
+Device Model:     Samsung SSD 860 EVO 500GB
+Serial Number:    S3Z1NB0K123456A
+Firmware Version: RVT04B6Q
+User Capacity:    500,107,862,016 bytes [500 GB]
+Sector Size:      512 bytes logical/physical
+
+=== START OF READ SMART DATA SECTION ===
+SMART overall-health self-assessment test result: PASSED
+
+SMART Attributes Data Structure revision number: 1
+Vendor Specific SMART Attributes with Thresholds:
+ID# ATTRIBUTE_NAME          FLAG     VALUE WORST THRESH TYPE      UPDATED  WHEN_FAILED RAW_VALUE
+  5 Reallocated_Sector_Ct   0x0033   100   100   010    Pre-fail  Always       -       3
+  9 Power_On_Hours          0x0032   095   095   000    Old_age   Always       -       21034
+ 12 Power_Cycle_Count       0x0032   099   099   000    Old_age   Always       -       150
+177 Wear_Leveling_Count     0x0013   093   093   000    Pre-fail  Always       -       58
+194 Temperature_Celsius     0x0022   065   052   000    Old_age   Always       -       35 (Min/Max 20/48)
+197 Current_Pending_Sector  0x0032   100   100   000    Old_age   Always       -       1
+199 UDMA_CRC_Error_Count    0x003e   100   100   000    Old_age   Always       -       0
+241 Total_LBAs_Written      0x0032   099   099   000    Old_age   Always       -       1000
+
+ATA Error Count: 2
+`
+
+const nvmeSample = `=== START OF INFORMATION SECTION ===
+Model Number:                       ADATA LEGEND 900
+Serial Number:                      2N1234567890
+Firmware Version:                   SP14107A
+Total NVM Capacity:                 2,048,408,248,320 [2.04 TB]
+
+=== START OF SMART DATA SECTION ===
+SMART overall-health self-assessment test result: FAILED!
+
+SMART/Health Information (NVMe Log 0x02)
+Critical Warning:                   0x04
+Temperature:                        41 Celsius
+Available Spare:                    100%
+Available Spare Threshold:          10%
+Percentage Used:                    2%
+Data Units Read:                    1,234 [631 MB]
+Data Units Written:                 4,667,015 [2.38 TB]
+Power Cycles:                       38
+Power On Hours:                     391
+Unsafe Shutdowns:                   12
+Media and Data Integrity Errors:    0
+Error Information Log Entries:      0
+`
+
+func TestParseSmartctl(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		model  string
+		serial string
+		health string
+		values map[string]float64
+	}{
+		{
+			name:   "ata",
+			text:   ataSample,
+			model:  "Samsung SSD 860 EVO 500GB",
+			serial: "S3Z1NB0K123456A",
+			health: "PASSED",
+			values: map[string]float64{
+				MetricCapacityBytes:   500107862016,
+				MetricReallocated:     3,
+				MetricPowerOnHours:    21034,
+				MetricPowerCycles:     150,
+				MetricTemperature:     35,
+				MetricPending:         1,
+				MetricCRCErrors:       0,
+				MetricBytesWritten:    512000,
+				MetricPercentageUsed:  7,
+				MetricErrorLogEntries: 2,
+				MetricHealthPassed:    1,
+			},
+		},
+		{
+			name:   "nvme",
+			text:   nvmeSample,
+			model:  "ADATA LEGEND 900",
+			serial: "2N1234567890",
+			health: "FAILED",
+			values: map[string]float64{
+				MetricCapacityBytes:   2048408248320,
+				MetricCriticalWarning: 4,
+				MetricTemperature:     41,
+				MetricAvailableSpare:  100,
+				MetricPercentageUsed:  2,
+				MetricBytesRead:       1234 * 512000,
+				MetricBytesWritten:    4667015 * 512000,
+				MetricPowerOnHours:    391,
+				MetricUnsafeShutdowns: 12,
+				MetricHealthPassed:    0,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := ParseSmartctl(tt.text)
+			if m.Model != tt.model || m.Serial != tt.serial || m.Health != tt.health {
+				t.Errorf("identity = %q %q %q, want %q %q %q", m.Model, m.Serial, m.Health, tt.model, tt.serial, tt.health)
+			}
+			for k, want := range tt.values {
+				if got, ok := m.Value(k); !ok || got != want {
+					t.Errorf("%s = %v (%v), want %v", k, got, ok, want)
+				}
+			}
+		})
+	}
+}
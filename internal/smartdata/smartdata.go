@@ -2,13 +2,28 @@ package smartdata
 
 import "time"
 
+// Коды ошибок агентов. Агент передаёт код и технические подробности в
+// RawError, а сервер по коду выбирает текст на языке получателя.
+const (
+	ErrCodeListDevices = "list_devices" // не удалось получить список дисков
+	ErrCodeSmartctl    = "smartctl"     // smartctl не смог прочитать данные диска
+	// ErrCodeAnalysis — код ошибки самого сервера: ни один поставщик LLM
+	// не дал заключения по данным диска
+	ErrCodeAnalysis = "analysis"
+)
+
 // CommonSMARTReport — единый формат отчёта от агентов (Windows/Linux)
 type CommonSMARTReport struct {
 	Hostname  string        `json:"hostname"`
 	OS        string        `json:"os"`        // "windows" или "linux"
 	Timestamp time.Time     `json:"timestamp"` // RFC3339
 	Devices   []SMARTDevice `json:"devices"`
-	RawError  string        `json:"raw_error,omitempty"`
+	RawError  string        `json:"raw_error,omitempty"`  // технические подробности ошибки, по-английски
+	ErrorCode string        `json:"error_code,omitempty"` // код ошибки ErrCode*, текст для людей по нему выбирает сервер
+
+	AgentVersion string   `json:"agent_version,omitempty"`
+	CronSchedule string   `json:"cron_schedule,omitempty"` // расписание агента, по нему сервер ждёт следующий отчёт
+	Labels       []string `json:"labels,omitempty"`        // метки хоста для маршрутизации уведомлений
 }
 
 // SMARTDevice — данные одного устройства
@@ -17,5 +32,6 @@ type SMARTDevice struct {
 	Type       string   `json:"type"`
 	SMARTData  string   `json:"smart_data"`
 	RawError   string   `json:"raw_error,omitempty"`
+	ErrorCode  string   `json:"error_code,omitempty"`
 	MountPaths []string `json:"mount_paths,omitempty"`
 }
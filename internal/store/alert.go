@@ -0,0 +1,44 @@
+package store
+
+import "time"
+
+// AlertState — состояние оповещений по устройству: последний учтённый уровень,
+// время последнего уведомления о нём и заданные пользователем ограничения
+type AlertState struct {
+	Level      Level     `json:"level"`
+	Since      time.Time `json:"since"`                // время отчёта, с которого действует уровень
+	NotifiedAt time.Time `json:"notified_at,omitzero"` // время отчёта последнего уведомления
+	ReportAt   time.Time `json:"report_at"`            // время последнего учтённого отчёта
+
+	AckedBy      string    `json:"acked_by,omitempty"` // кто подтвердил проблему текущего уровня
+	AckedAt      time.Time `json:"acked_at,omitzero"`
+	SnoozedUntil time.Time `json:"snoozed_until,omitzero"` // до какого момента не напоминать о проблеме
+	MutedBy      string    `json:"muted_by,omitempty"`     // кто отключил оповещения по устройству
+	Muted        bool      `json:"muted,omitempty"`
+
+	// AnalysisFailed — по последнему отчёту заключение LLM не получено и об
+	// этом уже сообщено; сбрасывается с первым полученным заключением
+	AnalysisFailed bool `json:"analysis_failed,omitempty"`
+}
+
+// Alert возвращает состояние оповещений по устройству хоста
+func (h Host) Alert(device string) (AlertState, bool) {
+	a, ok := h.Alerts[device]
+	return a, ok
+}
+
+// UpdateAlert атомарно изменяет состояние оповещений по устройству хоста.
+// Для устройства без состояния fn получает пустой AlertState.
+func (s *Store) UpdateAlert(host, device string, fn func(a *AlertState)) (AlertState, error) {
+	var ret AlertState
+	_, err := s.UpdateHost(host, func(h *Host) bool {
+		if h.Alerts == nil {
+			h.Alerts = make(map[string]AlertState)
+		}
+		ret = h.Alerts[device]
+		fn(&ret)
+		h.Alerts[device] = ret
+		return true
+	})
+	return ret, err
+}
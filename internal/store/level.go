@@ -0,0 +1,65 @@
+package store
+
+import "strings"
+
+// Level — итоговая оценка состояния устройства
+type Level string
+
+const (
+	LevelUnknown  Level = "unknown"
+	LevelOK       Level = "ok"
+	LevelWarning  Level = "warning"
+	LevelCritical Level = "critical"
+)
+
+// Rank упорядочивает уровни по тяжести: unknown < ok < warning < critical
+func (l Level) Rank() int {
+	switch l {
+	case LevelOK:
+		return 1
+	case LevelWarning:
+		return 2
+	case LevelCritical:
+		return 3
+	}
+	return 0
+}
+
+// Emoji возвращает значок уровня в том же виде, что использует LLM в ответах
+func (l Level) Emoji() string {
+	switch l {
+	case LevelOK:
+		return "✅"
+	case LevelWarning:
+		return "⚠️"
+	case LevelCritical:
+		return "🔴"
+	}
+	return "❔"
+}
+
+// LevelFromText определяет уровень по значкам в свободном тексте анализа LLM:
+// берётся самый тяжёлый из встреченных
+func LevelFromText(text string) Level {
+	switch {
+	case strings.Contains(text, "🔴"):
+		return LevelCritical
+	case strings.Contains(text, "⚠"):
+		return LevelWarning
+	case strings.Contains(text, "✅"):
+		return LevelOK
+	}
+	return LevelUnknown
+}
+
+// Verdict возвращает уровень анализа; для старых записей без уровня он
+// определяется по тексту
+func (a *Analysis) Verdict() Level {
+	if a == nil {
+		return LevelUnknown
+	}
+	if a.Level != "" {
+		return a.Level
+	}
+	return LevelFromText(a.Text)
+}
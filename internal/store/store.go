@@ -0,0 +1,343 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/covrom/smart-control/internal/smartdata"
+)
+
+// Host — последнее известное состояние хоста-агента
+type Host struct {
+	Hostname     string    `json:"hostname"`
+	OS           string    `json:"os"`
+	AgentVersion string    `json:"agent_version,omitempty"`
+	LastReport   time.Time `json:"last_report"` // время отчёта по часам агента
+	ReceivedAt   time.Time `json:"received_at"` // время приёма отчёта сервером
+	Devices      []string  `json:"devices"`
+	RawError     string    `json:"raw_error,omitempty"`
+	ErrorCode    string    `json:"error_code,omitempty"` // код ошибки smartdata.ErrCode*
+	CronSchedule string    `json:"cron_schedule,omitempty"`
+	Labels       []string  `json:"labels,omitempty"`
+	MissingSince time.Time `json:"missing_since,omitzero"` // с какого момента хост пропустил отчёт
+
+	Alerts map[string]AlertState `json:"alerts,omitempty"` // по имени устройства
+}
+
+// Analysis — результат анализа снимка с помощью LLM. Поля заключения
+// (Headline и далее) заполнены, если LLM ответила по схеме JSON; у старых
+// записей и ответов свободным текстом есть только Text.
+type Analysis struct {
+	Text     string   `json:"text"`
+	Level    Level    `json:"level,omitempty"`
+	Headline string   `json:"headline,omitempty"` // краткий итог
+	Findings []string `json:"findings,omitempty"` // замеченные проблемы и наблюдения
+	Actions  []string `json:"actions,omitempty"`  // рекомендуемые действия
+	Drive    *Drive   `json:"drive,omitempty"`
+	Provider string   `json:"provider,omitempty"` // поставщик LLM, давший заключение
+	Model    string   `json:"model,omitempty"`    // модель этого поставщика
+	// Fingerprint — отпечаток показателей снимка, по которому сделано заключение
+	// (см. smartdata.Metrics.Fingerprint)
+	Fingerprint string    `json:"fingerprint,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	// RefreshedAt — когда заключение повторено для нового снимка без запроса
+	// к LLM; CreatedAt при этом остаётся временем запроса
+	RefreshedAt time.Time `json:"refreshed_at,omitzero"`
+}
+
+// Drive — сведения о модели диска по оценке LLM; неизвестные поля пустые
+type Drive struct {
+	Brand        string `json:"brand"`
+	Model        string `json:"model"`
+	Type         string `json:"type"` // HDD, SSD или NVMe
+	FormFactor   string `json:"form_factor"`
+	Capacity     string `json:"capacity"`
+	Endurance    string `json:"endurance"` // заявленный ресурс записи (TBW) или MTBF
+	PowerOnHours string `json:"power_on_hours"`
+	TotalWritten string `json:"total_written"`
+}
+
+// Snapshot — данные одного устройства из одного отчёта
+type Snapshot struct {
+	Hostname  string                `json:"hostname"`
+	Timestamp time.Time             `json:"timestamp"`
+	Device    smartdata.SMARTDevice `json:"device"`
+	Metrics   smartdata.Metrics     `json:"metrics"`
+	Analysis  *Analysis             `json:"analysis,omitempty"`
+}
+
+// Store — файловое хранилище хостов и истории снимков устройств.
+//
+// Раскладка каталога:
+//
+//	hosts/<host>.json                   — Host
+//	history/<host>/<device>/<time>.json — Snapshot
+//	<host>_<device>.json                — последний снимок в старом формате (только чтение)
+type Store struct {
+	dir string
+	mu  sync.RWMutex
+}
+
+// tsLayout — формат времени в именах файлов истории, лексикографический порядок
+// совпадает с хронологическим
+const tsLayout = "20060102T150405.000000000Z"
+
+// Open открывает хранилище в каталоге dir
+func Open(dir string) (*Store, error) {
+	for _, sub := range []string{"hosts", "history"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			return nil, fmt.Errorf("create store dir: %w", err)
+		}
+	}
+	return &Store{dir: dir}, nil
+}
+
+// safeName заменяет небезопасные для имени файла символы на '_'
+func safeName(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|':
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// DeviceID возвращает короткий идентификатор устройства, пригодный для URL и имён файлов:
+// "/dev/sda" → "sda", "/dev/nvme0" → "nvme0"
+func DeviceID(device string) string {
+	return safeName(strings.TrimPrefix(device, "/dev/"))
+}
+
+func writeJSON(filename string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	tmp := filename + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filename)
+}
+
+func readJSON(filename string, v any) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func (s *Store) hostFile(host string) string {
+	return filepath.Join(s.dir, "hosts", safeName(host)+".json")
+}
+
+func (s *Store) deviceDir(host, device string) string {
+	return filepath.Join(s.dir, "history", safeName(host), DeviceID(device))
+}
+
+// SaveHost сохраняет состояние хоста
+func (s *Store) SaveHost(h Host) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return writeJSON(s.hostFile(h.Hostname), h)
+}
+
+// UpdateHost атомарно читает, изменяет функцией fn и сохраняет состояние хоста.
+// Для неизвестного хоста fn получает пустой Host. Если fn возвращает false,
+// изменения не сохраняются.
+func (s *Store) UpdateHost(name string, fn func(h *Host) bool) (Host, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var h Host
+	if err := readJSON(s.hostFile(name), &h); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return h, err
+	}
+	if !fn(&h) {
+		return h, nil
+	}
+	return h, writeJSON(s.hostFile(name), h)
+}
+
+// Host возвращает состояние хоста по имени
+func (s *Store) Host(name string) (Host, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var h Host
+	err := readJSON(s.hostFile(name), &h)
+	if errors.Is(err, os.ErrNotExist) {
+		return h, false, nil
+	}
+	return h, err == nil, err
+}
+
+// Hosts возвращает все известные хосты, упорядоченные по имени
+func (s *Store) Hosts() ([]Host, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries, err := os.ReadDir(filepath.Join(s.dir, "hosts"))
+	if err != nil {
+		return nil, err
+	}
+	var hosts []Host
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		var h Host
+		if err := readJSON(filepath.Join(s.dir, "hosts", e.Name()), &h); err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, h)
+	}
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i].Hostname < hosts[j].Hostname })
+	return hosts, nil
+}
+
+// FindDevice ищет имя устройства хоста по его DeviceID
+func (s *Store) FindDevice(host, id string) (string, bool, error) {
+	h, ok, err := s.Host(host)
+	if !ok || err != nil {
+		return "", false, err
+	}
+	for _, d := range h.Devices {
+		if DeviceID(d) == id || d == id {
+			return d, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// SaveSnapshot сохраняет снимок устройства. Снимок с тем же временем перезаписывается.
+func (s *Store) SaveSnapshot(snap Snapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name := snap.Timestamp.UTC().Format(tsLayout) + ".json"
+	return writeJSON(filepath.Join(s.deviceDir(snap.Hostname, snap.Device.Device), name), snap)
+}
+
+// snapshotNames возвращает имена файлов истории устройства в хронологическом порядке
+func (s *Store) snapshotNames(host, device string) ([]string, error) {
+	entries, err := os.ReadDir(s.deviceDir(host, device))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && filepath.Ext(e.Name()) == ".json" {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s *Store) readSnapshot(host, device, name string) (Snapshot, error) {
+	var snap Snapshot
+	err := readJSON(filepath.Join(s.deviceDir(host, device), name), &snap)
+	return snap, err
+}
+
+// Snapshot возвращает снимок устройства на момент ts
+func (s *Store) Snapshot(host, device string, ts time.Time) (Snapshot, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	snap, err := s.readSnapshot(host, device, ts.UTC().Format(tsLayout)+".json")
+	if errors.Is(err, os.ErrNotExist) {
+		return snap, false, nil
+	}
+	return snap, err == nil, err
+}
+
+// Latest возвращает последний снимок устройства
+func (s *Store) Latest(host, device string) (Snapshot, bool, error) {
+	return s.Previous(host, device, time.Time{})
+}
+
+// Previous возвращает последний снимок устройства, сделанный раньше before
+// (нулевой before — без ограничения). Если истории ещё нет, используется
+// файл последнего снимка в старом формате.
+func (s *Store) Previous(host, device string, before time.Time) (Snapshot, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names, err := s.snapshotNames(host, device)
+	if err != nil {
+		return Snapshot{}, false, err
+	}
+	limit := before.UTC().Format(tsLayout) + ".json"
+	for i := len(names) - 1; i >= 0; i-- {
+		if before.IsZero() || names[i] < limit {
+			snap, err := s.readSnapshot(host, device, names[i])
+			return snap, err == nil, err
+		}
+	}
+	if len(names) > 0 {
+		return Snapshot{}, false, nil
+	}
+
+	// старый формат: <host>_<device>.json с последним SMARTDevice
+	var dev smartdata.SMARTDevice
+	err = readJSON(filepath.Join(s.dir, safeName(fmt.Sprintf("%s_%s.json", host, device))), &dev)
+	if errors.Is(err, os.ErrNotExist) {
+		return Snapshot{}, false, nil
+	}
+	if err != nil {
+		return Snapshot{}, false, err
+	}
+	return Snapshot{
+		Hostname: host,
+		Device:   dev,
+		Metrics:  smartdata.ParseSmartctl(dev.SMARTData),
+	}, true, nil
+}
+
+// History возвращает снимки устройства за интервал [from, to] (нулевые границы —
+// без ограничения), от новых к старым
+func (s *Store) History(host, device string, from, to time.Time) ([]Snapshot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names, err := s.snapshotNames(host, device)
+	if err != nil {
+		return nil, err
+	}
+	var ret []Snapshot
+	for i := len(names) - 1; i >= 0; i-- {
+		ts, err := time.Parse(tsLayout, strings.TrimSuffix(names[i], ".json"))
+		if err != nil {
+			continue
+		}
+		if !to.IsZero() && ts.After(to) {
+			continue
+		}
+		if !from.IsZero() && ts.Before(from) {
+			break
+		}
+		snap, err := s.readSnapshot(host, device, names[i])
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, snap)
+	}
+	return ret, nil
+}
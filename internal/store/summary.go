@@ -0,0 +1,78 @@
+package store
+
+import "time"
+
+// DeviceSummary — краткое состояние устройства по последнему снимку
+type DeviceSummary struct {
+	ID           string             `json:"id"`
+	Device       string             `json:"device"`
+	Type         string             `json:"type,omitempty"`
+	Model        string             `json:"model,omitempty"`
+	Serial       string             `json:"serial,omitempty"`
+	MountPaths   []string           `json:"mount_paths,omitempty"`
+	LastSnapshot time.Time          `json:"last_snapshot,omitzero"`
+	Health       string             `json:"health,omitempty"`
+	Values       map[string]float64 `json:"values,omitempty"`
+	Analysis     *Analysis          `json:"analysis,omitempty"`
+}
+
+// DeviceLevel возвращает уровень устройства хоста и время, с которого он
+// действует. Уровень берётся из состояния оповещений: unknown не снимает
+// известную проблему. Без состояния — заключение последнего снимка.
+func (h Host) DeviceLevel(sum DeviceSummary) (Level, time.Time) {
+	if a, ok := h.Alert(sum.Device); ok && a.Level != "" {
+		return a.Level, a.Since
+	}
+	return sum.Analysis.Verdict(), time.Time{}
+}
+
+// SeriesPoint — точка временного ряда показателя
+type SeriesPoint struct {
+	Time  time.Time `json:"t"`
+	Value float64   `json:"v"`
+}
+
+// NewDeviceSummary собирает краткое состояние устройства из снимка
+func NewDeviceSummary(device string, snap Snapshot) DeviceSummary {
+	return DeviceSummary{
+		ID:           DeviceID(device),
+		Device:       device,
+		Type:         snap.Device.Type,
+		Model:        snap.Metrics.Model,
+		Serial:       snap.Metrics.Serial,
+		MountPaths:   snap.Device.MountPaths,
+		LastSnapshot: snap.Timestamp,
+		Health:       snap.Metrics.Health,
+		Values:       snap.Metrics.Values,
+		Analysis:     snap.Analysis,
+	}
+}
+
+// DeviceSummaries возвращает краткое состояние всех устройств хоста
+func (s *Store) DeviceSummaries(h Host) ([]DeviceSummary, error) {
+	ret := make([]DeviceSummary, 0, len(h.Devices))
+	for _, d := range h.Devices {
+		snap, _, err := s.Latest(h.Hostname, d)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, NewDeviceSummary(d, snap))
+	}
+	return ret, nil
+}
+
+// Series строит временные ряды показателей в хронологическом порядке
+// по истории, упорядоченной от новых снимков к старым
+func Series(history []Snapshot, metrics ...string) map[string][]SeriesPoint {
+	ret := make(map[string][]SeriesPoint, len(metrics))
+	for _, m := range metrics {
+		points := []SeriesPoint{}
+		for i := len(history) - 1; i >= 0; i-- {
+			if v, ok := history[i].Metrics.Value(m); ok {
+				points = append(points, SeriesPoint{Time: history[i].Timestamp, Value: v})
+			}
+		}
+		ret[m] = points
+	}
+	return ret
+}
@@ -0,0 +1,131 @@
+// Package textdiff строит построчное сравнение двух текстов в две колонки,
+// как diff -y: удобно сравнивать выводы smartctl разных снимков.
+package textdiff
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxCells ограничивает размер таблицы LCS; для больших текстов строки
+// сравниваются попарно без поиска общей подпоследовательности
+const maxCells = 4_000_000
+
+// op — строка результата: общая, удалённая, добавленная или изменённая
+type op struct {
+	kind        byte // ' ', '<', '>', '|'
+	left, right string
+}
+
+// SideBySide сравнивает тексты old и new и выводит их в две колонки шириной
+// width символов. Между колонками стоит маркер: пробел — строки совпадают,
+// «|» — строка изменена, «<» — есть только слева, «>» — только справа.
+func SideBySide(old, new string, width int) string {
+	a, b := lines(old), lines(new)
+	var b2 strings.Builder
+	for _, o := range diff(a, b) {
+		fmt.Fprintf(&b2, "%s %c %s\n", pad(o.left, width), o.kind, clip(o.right, width))
+	}
+	return b2.String()
+}
+
+// Changed сообщает, различаются ли тексты по строкам
+func Changed(old, new string) bool {
+	for _, o := range diff(lines(old), lines(new)) {
+		if o.kind != ' ' {
+			return true
+		}
+	}
+	return false
+}
+
+func lines(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.TrimSuffix(s, "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// diff выравнивает строки по наибольшей общей подпоследовательности;
+// подряд идущие удаления и добавления объединяются в изменённые строки
+func diff(a, b []string) []op {
+	if len(a)*len(b) > maxCells {
+		return pairwise(a, b)
+	}
+
+	// lcs[i][j] — длина НОП для a[i:] и b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var ret []op
+	var del, ins []string
+	flush := func() {
+		ret = append(ret, pairwise(del, ins)...)
+		del, ins = del[:0], ins[:0]
+	}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			flush()
+			ret = append(ret, op{' ', a[i], b[j]})
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			del = append(del, a[i])
+			i++
+		default:
+			ins = append(ins, b[j])
+			j++
+		}
+	}
+	flush()
+	return ret
+}
+
+// pairwise сопоставляет строки по порядку: пары — изменённые строки,
+// остаток — удалённые или добавленные
+func pairwise(a, b []string) []op {
+	var ret []op
+	for i := 0; i < max(len(a), len(b)); i++ {
+		switch {
+		case i >= len(a):
+			ret = append(ret, op{'>', "", b[i]})
+		case i >= len(b):
+			ret = append(ret, op{'<', a[i], ""})
+		case a[i] == b[i]:
+			ret = append(ret, op{' ', a[i], b[i]})
+		default:
+			ret = append(ret, op{'|', a[i], b[i]})
+		}
+	}
+	return ret
+}
+
+// clip обрезает строку до width символов, отмечая обрезку «…»
+func clip(s string, width int) string {
+	s = strings.ReplaceAll(s, "\t", "    ")
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	return string([]rune(s)[:width-1]) + "…"
+}
+
+func pad(s string, width int) string {
+	s = clip(s, width)
+	return s + strings.Repeat(" ", width-utf8.RuneCountInString(s))
+}
@@ -0,0 +1,35 @@
+package textdiff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSideBySide(t *testing.T) {
+	old := "header\n  5 Reallocated_Sector_Ct 0\n194 Temperature 35\nfooter\n"
+	new := "header\n  5 Reallocated_Sector_Ct 8\n194 Temperature 35\n197 Current_Pending 2\nfooter\n"
+
+	got := SideBySide(old, new, 30)
+	want := strings.Join([]string{
+		"header                           header",
+		"  5 Reallocated_Sector_Ct 0    |   5 Reallocated_Sector_Ct 8",
+		"194 Temperature 35               194 Temperature 35",
+		"                               > 197 Current_Pending 2",
+		"footer                           footer",
+		"",
+	}, "\n")
+	if got != want {
+		t.Errorf("SideBySide:\n%s\nwant:\n%s", got, want)
+	}
+
+	if !Changed(old, new) || Changed(old, old) {
+		t.Error("Changed is wrong")
+	}
+}
+
+func TestSideBySide_clip(t *testing.T) {
+	got := SideBySide("abcdefghij", "", 5)
+	if got != "abcd… < \n" {
+		t.Errorf("got %q", got)
+	}
+}
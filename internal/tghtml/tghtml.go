@@ -0,0 +1,328 @@
+// Package tghtml готовит текст сообщений в разметке HTML, которую понимает
+// Telegram: экранирование, перевод Markdown из ответов LLM, моноширинные
+// таблицы и разбиение длинного текста на части без разрыва тегов и сущностей.
+package tghtml
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// MaxMessageLen — ограничение Telegram на длину сообщения
+const MaxMessageLen = 4096
+
+var escaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// Escape экранирует текст для вставки в HTML-сообщение
+func Escape(s string) string {
+	return escaper.Replace(s)
+}
+
+// Bold, Code и Pre оформляют экранированный текст
+func Bold(s string) string { return "<b>" + Escape(s) + "</b>" }
+func Code(s string) string { return "<code>" + Escape(s) + "</code>" }
+func Pre(s string) string  { return "<pre>" + Escape(s) + "</pre>" }
+
+// Expandable — свёрнутая по умолчанию цитата для объёмных подробностей;
+// html должен быть уже размечен
+func Expandable(html string) string {
+	return "<blockquote expandable>" + html + "</blockquote>"
+}
+
+var (
+	mdBold   = regexp.MustCompile(`\*\*(.+?)\*\*|__(.+?)__`)
+	mdItalic = regexp.MustCompile(`(^|[\s(])[*_]([^*_\s](?:[^*_]*[^*_\s])?)[*_]([\s.,;:!?)]|$)`)
+	mdCode   = regexp.MustCompile("`([^`]+)`")
+	mdHeader = regexp.MustCompile(`^#{1,6}\s+(.*)$`)
+	mdBullet = regexp.MustCompile(`^(\s*)[-*+]\s+`)
+)
+
+// Markdown переводит Markdown из ответов LLM в HTML Telegram: заголовки,
+// жирный и курсив, код и блоки кода, маркированные списки. Остальной текст
+// экранируется.
+func Markdown(md string) string {
+	var b strings.Builder
+	lines := strings.Split(strings.ReplaceAll(md, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			// блок кода до закрывающих ``` или до конца текста
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), "```"); i++ {
+				code = append(code, lines[i])
+			}
+			b.WriteString(Pre(strings.Join(code, "\n")))
+		} else if m := mdHeader.FindStringSubmatch(line); m != nil {
+			b.WriteString("<b>" + inline(strings.Trim(m[1], "*_ ")) + "</b>")
+		} else {
+			if m := mdBullet.FindStringSubmatch(line); m != nil {
+				line = m[1] + "• " + line[len(m[0]):]
+			}
+			b.WriteString(inline(line))
+		}
+		if i < len(lines)-1 {
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// inline размечает строку: код не размечается внутри, остальное экранируется
+func inline(s string) string {
+	var b strings.Builder
+	for {
+		loc := mdCode.FindStringSubmatchIndex(s)
+		if loc == nil {
+			b.WriteString(emphasis(Escape(s)))
+			return b.String()
+		}
+		b.WriteString(emphasis(Escape(s[:loc[0]])))
+		b.WriteString(Code(s[loc[2]:loc[3]]))
+		s = s[loc[1]:]
+	}
+}
+
+func emphasis(s string) string {
+	s = mdBold.ReplaceAllStringFunc(s, func(m string) string {
+		return "<b>" + m[2:len(m)-2] + "</b>"
+	})
+	return mdItalic.ReplaceAllString(s, "$1<i>$2</i>$3")
+}
+
+// Table форматирует строки в моноширинную таблицу с выравниванием столбцов
+func Table(header []string, rows [][]string) string {
+	widths := make([]int, len(header))
+	all := append([][]string{header}, rows...)
+	for _, r := range all {
+		for i, c := range r {
+			if i < len(widths) {
+				widths[i] = max(widths[i], utf8.RuneCountInString(c))
+			}
+		}
+	}
+	var b strings.Builder
+	for n, r := range all {
+		for i, c := range r {
+			if i >= len(widths) {
+				break
+			}
+			if i > 0 {
+				b.WriteString(" ")
+			}
+			if i == len(widths)-1 {
+				b.WriteString(c)
+			} else {
+				fmt.Fprintf(&b, "%-*s", widths[i], c)
+			}
+		}
+		if n < len(all)-1 {
+			b.WriteString("\n")
+		}
+	}
+	return Pre(b.String())
+}
+
+// TextLen — длина текста в единицах UTF-16, в которых Telegram считает
+// ограничения (см. MaxMessageLen)
+func TextLen(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
+
+// token — тег, сущность или фрагмент текста
+type token struct {
+	s    string
+	tag  string // имя тега для открывающих и закрывающих тегов
+	open bool
+}
+
+func tokenize(s string) []token {
+	var ret []token
+	for len(s) > 0 {
+		switch {
+		case s[0] == '<':
+			end := strings.IndexByte(s, '>')
+			if end < 0 {
+				ret = append(ret, token{s: s})
+				return ret
+			}
+			t := token{s: s[:end+1]}
+			name := strings.TrimPrefix(s[1:end], "/")
+			if i := strings.IndexAny(name, " \t\n"); i >= 0 {
+				name = name[:i]
+			}
+			t.tag, t.open = name, s[1] != '/'
+			ret = append(ret, t)
+			s = s[end+1:]
+		case s[0] == '&':
+			end := strings.IndexByte(s, ';')
+			if end < 0 || end > 10 {
+				ret = append(ret, token{s: "&"})
+				s = s[1:]
+				continue
+			}
+			ret = append(ret, token{s: s[:end+1]})
+			s = s[end+1:]
+		default:
+			end := strings.IndexAny(s, "<&")
+			if end < 0 {
+				end = len(s)
+			}
+			// текст режем по символам, чтобы его можно было разделить в любом месте
+			for _, r := range s[:end] {
+				ret = append(ret, token{s: string(r)})
+			}
+			s = s[end:]
+		}
+	}
+	return ret
+}
+
+// Split разбивает HTML на части не длиннее maxLen (в единицах UTF-16 вместе
+// с разметкой). Разрыв делается по возможности на переводе строки, иначе на
+// пробеле; открытые теги закрываются в конце части и открываются заново в
+// следующей, сущности вида &amp; не разрываются.
+func Split(html string, maxLen int) []string {
+	if TextLen(html) <= maxLen {
+		return []string{html}
+	}
+
+	tokens := tokenize(html)
+	var parts []string
+	var stack []token // открытые теги
+	for len(tokens) > 0 {
+		var b strings.Builder
+		prefix := ""
+		for _, t := range stack {
+			prefix += t.s
+		}
+		b.WriteString(prefix)
+		n := TextLen(prefix)
+
+		// сколько токенов помещается с учётом закрывающих тегов
+		cur := append([]token(nil), stack...)
+		fit, lastNL, lastSpace := 0, -1, -1
+		var nlStack, spStack []token
+		for fit < len(tokens) {
+			t := tokens[fit]
+			next := cur
+			if t.tag != "" {
+				next = applyTag(cur, t)
+			}
+			if n+TextLen(t.s)+closeLen(next) > maxLen {
+				break
+			}
+			n += TextLen(t.s)
+			cur = next
+			fit++
+			switch t.s {
+			case "\n":
+				lastNL, nlStack = fit, append([]token(nil), cur...)
+			case " ":
+				lastSpace, spStack = fit, append([]token(nil), cur...)
+			}
+		}
+		cut := fit
+		if fit < len(tokens) {
+			switch {
+			case lastNL > 0:
+				cut, cur = lastNL, nlStack
+			case lastSpace > 0:
+				cut, cur = lastSpace, spStack
+			}
+		}
+		if cut == 0 {
+			// даже один токен не помещается — отправляем как есть
+			cut = 1
+			if tokens[0].tag != "" {
+				cur = applyTag(cur, tokens[0])
+			}
+		}
+		for _, t := range tokens[:cut] {
+			b.WriteString(t.s)
+		}
+		for i := len(cur) - 1; i >= 0; i-- {
+			b.WriteString("</" + cur[i].tag + ">")
+		}
+		if part := strings.TrimSpace(b.String()); part != "" && !onlyTags(part) {
+			parts = append(parts, b.String())
+		}
+		tokens = tokens[cut:]
+		stack = cur
+	}
+	return parts
+}
+
+func applyTag(stack []token, t token) []token {
+	if t.open {
+		return append(append([]token(nil), stack...), t)
+	}
+	for i := len(stack) - 1; i >= 0; i-- {
+		if stack[i].tag == t.tag {
+			return append(append([]token(nil), stack[:i]...), stack[i+1:]...)
+		}
+	}
+	return stack
+}
+
+func closeLen(stack []token) int {
+	n := 0
+	for _, t := range stack {
+		n += len(t.tag) + 3
+	}
+	return n
+}
+
+// onlyTags сообщает, что в части нет текста, только разметка
+func onlyTags(s string) bool {
+	for _, t := range tokenize(s) {
+		if t.tag == "" && strings.TrimSpace(t.s) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// Plain убирает разметку, оставляя текст: запасной вариант, если Telegram
+// не смог разобрать HTML
+func Plain(s string) string {
+	var b strings.Builder
+	for _, t := range tokenize(s) {
+		if t.tag == "" {
+			b.WriteString(t.s)
+		}
+	}
+	// сущности раскрываем все, включая числовые: их выводит html/template
+	return html.UnescapeString(b.String())
+}
+
+// HTML — уже размеченный текст, Sprintf вставляет его без экранирования
+type HTML string
+
+// Sprintf форматирует как fmt.Sprintf, экранируя аргументы-строки, ошибки и
+// значения с методом String; format и аргументы типа HTML не экранируются
+func Sprintf(format string, args ...any) string {
+	esc := make([]any, len(args))
+	for i, a := range args {
+		switch v := a.(type) {
+		case HTML:
+			esc[i] = string(v)
+		case string:
+			esc[i] = Escape(v)
+		case error:
+			esc[i] = Escape(v.Error())
+		case fmt.Stringer:
+			esc[i] = Escape(v.String())
+		default:
+			esc[i] = a
+		}
+	}
+	return fmt.Sprintf(format, esc...)
+}
@@ -0,0 +1,79 @@
+package tghtml
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMarkdown(t *testing.T) {
+	md := "### Итог\n**Диск** в порядке, *но* `Reallocated_Sector_Ct` > 0 & растёт\n- пункт <1>\n```\nID# ATTRIBUTE\n  5 Realloc\n```"
+	want := "<b>Итог</b>\n<b>Диск</b> в порядке, <i>но</i> <code>Reallocated_Sector_Ct</code> &gt; 0 &amp; растёт\n• пункт &lt;1&gt;\n<pre>ID# ATTRIBUTE\n  5 Realloc</pre>"
+	if got := Markdown(md); got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	line := "<b>заголовок</b> текст &amp; ещё <code>код</code>\n"
+	html := "<blockquote expandable><pre>" + strings.Repeat("строка таблицы &lt;1&gt;\n", 40) + "</pre></blockquote>\n" + strings.Repeat(line, 20)
+
+	const maxLen = 200
+	parts := Split(html, maxLen)
+	if len(parts) < 2 {
+		t.Fatalf("expected several parts, got %d", len(parts))
+	}
+	var text strings.Builder
+	for i, p := range parts {
+		if n := TextLen(p); n > maxLen {
+			t.Errorf("part %d too long: %d", i, n)
+		}
+		// теги в каждой части сбалансированы, сущности не разорваны
+		var stack []string
+		for _, tok := range tokenize(p) {
+			switch {
+			case tok.tag != "" && tok.open:
+				stack = append(stack, tok.tag)
+			case tok.tag != "":
+				if len(stack) == 0 || stack[len(stack)-1] != tok.tag {
+					t.Fatalf("part %d: unbalanced </%s>:\n%s", i, tok.tag, p)
+				}
+				stack = stack[:len(stack)-1]
+			case tok.s == "&":
+				t.Fatalf("part %d: broken entity:\n%s", i, p)
+			}
+		}
+		if len(stack) > 0 {
+			t.Fatalf("part %d: unclosed %v:\n%s", i, stack, p)
+		}
+		text.WriteString(stripTags(p))
+	}
+	if got, want := text.String(), stripTags(html); strings.ReplaceAll(got, "\n", "") != strings.ReplaceAll(want, "\n", "") {
+		t.Fatalf("text lost in split:\n%q\n%q", got, want)
+	}
+}
+
+func TestSplitShort(t *testing.T) {
+	if parts := Split("<b>a</b>", MaxMessageLen); len(parts) != 1 || parts[0] != "<b>a</b>" {
+		t.Fatalf("unexpected %q", parts)
+	}
+}
+
+func stripTags(s string) string {
+	var b strings.Builder
+	for _, tok := range tokenize(s) {
+		if tok.tag == "" {
+			b.WriteString(tok.s)
+		}
+	}
+	return b.String()
+}
+
+func TestSprintf(t *testing.T) {
+	got := Sprintf("<b>%s</b> %s %d", "a<b>", HTML("<i>x</i>"), 5)
+	if want := "<b>a&lt;b&gt;</b> <i>x</i> 5"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if got := Plain("<b>a &amp; b</b>"); got != "a & b" {
+		t.Fatalf("Plain: %q", got)
+	}
+}
@@ -0,0 +1,5 @@
+package version
+
+// Version — версия сборки, передаётся агентом в отчёте.
+// Задаётся при сборке: go build -ldflags "-X github.com/covrom/smart-control/internal/version.Version=1.2.0"
+var Version = "dev"
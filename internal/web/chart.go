@@ -0,0 +1,78 @@
+package web
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+
+	"github.com/covrom/smart-control/internal/i18n"
+	"github.com/covrom/smart-control/internal/store"
+)
+
+const (
+	chartWidth   = 600
+	chartHeight  = 160
+	chartPadLeft = 70
+	chartPadY    = 20
+)
+
+// chart рисует временной ряд в виде встроенного SVG (без внешних библиотек),
+// даты подписываются на языке l
+func chart(l i18n.Lang, points []store.SeriesPoint, name string) template.HTML {
+	if len(points) == 0 {
+		return ""
+	}
+	minV, maxV := points[0].Value, points[0].Value
+	for _, p := range points {
+		minV = min(minV, p.Value)
+		maxV = max(maxV, p.Value)
+	}
+	if maxV == minV {
+		maxV = minV + 1
+	}
+	t0 := points[0].Time
+	span := points[len(points)-1].Time.Sub(t0).Seconds()
+	if span <= 0 {
+		span = 1
+	}
+
+	plotW := float64(chartWidth - chartPadLeft - 10)
+	plotH := float64(chartHeight - 2*chartPadY)
+	x := func(p store.SeriesPoint) float64 {
+		if len(points) == 1 {
+			return chartPadLeft + plotW/2
+		}
+		return chartPadLeft + p.Time.Sub(t0).Seconds()/span*plotW
+	}
+	y := func(v float64) float64 {
+		return chartPadY + (maxV-v)/(maxV-minV)*plotH
+	}
+
+	label := func(v float64) string {
+		return metric(map[string]float64{name: v}, name)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg class="chart" viewBox="0 0 %d %d" xmlns="http://www.w3.org/2000/svg">`, chartWidth, chartHeight)
+	fmt.Fprintf(&b, `<line class="axis" x1="%d" y1="%d" x2="%d" y2="%d"/>`, chartPadLeft, chartPadY, chartPadLeft, chartHeight-chartPadY)
+	fmt.Fprintf(&b, `<line class="axis" x1="%d" y1="%d" x2="%d" y2="%d"/>`, chartPadLeft, chartHeight-chartPadY, chartWidth-10, chartHeight-chartPadY)
+	fmt.Fprintf(&b, `<text x="%d" y="%.1f" text-anchor="end">%s</text>`, chartPadLeft-6, y(maxV)+4, template.HTMLEscapeString(label(maxV)))
+	fmt.Fprintf(&b, `<text x="%d" y="%.1f" text-anchor="end">%s</text>`, chartPadLeft-6, y(minV)+4, template.HTMLEscapeString(label(minV)))
+	fmt.Fprintf(&b, `<text x="%d" y="%d">%s</text>`, chartPadLeft, chartHeight-4, l.Date(points[0].Time))
+	fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end">%s</text>`, chartWidth-10, chartHeight-4, l.Date(points[len(points)-1].Time))
+
+	b.WriteString(`<polyline class="line" points="`)
+	for i, p := range points {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		fmt.Fprintf(&b, "%.1f,%.1f", x(p), y(p.Value))
+	}
+	b.WriteString(`"/>`)
+	for _, p := range points {
+		fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="2.5"><title>%s: %s</title></circle>`,
+			x(p), y(p.Value), l.DateTime(p.Time), template.HTMLEscapeString(label(p.Value)))
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
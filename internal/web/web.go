@@ -0,0 +1,233 @@
+package web
+
+import (
+	"crypto/subtle"
+	"embed"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/covrom/smart-control/internal/i18n"
+	"github.com/covrom/smart-control/internal/smartdata"
+	"github.com/covrom/smart-control/internal/store"
+)
+
+//go:embed templates/*.html
+var templatesFS embed.FS
+
+//go:embed static
+var staticFS embed.FS
+
+// chartMetrics — показатели, для которых на странице устройства строятся графики
+// (подписи — по ключам каталога web.metric.<показатель>)
+var chartMetrics = []string{
+	smartdata.MetricTemperature,
+	smartdata.MetricReallocated,
+	smartdata.MetricPending,
+	smartdata.MetricPercentageUsed,
+	smartdata.MetricBytesWritten,
+	smartdata.MetricPowerOnHours,
+}
+
+// historyLimit — сколько последних снимков показывать на странице устройства
+const historyLimit = 30
+
+// pages — шаблоны страниц на языке l
+type pages struct {
+	l      i18n.Lang
+	index  *template.Template
+	device *template.Template
+}
+
+func newPages(l i18n.Lang) pages {
+	funcs := template.FuncMap{
+		"t":        l.T,
+		"tf":       l.Sprintf,
+		"lang":     func() string { return string(l) },
+		"level":    func(v store.Level) string { return l.T("level." + string(v)) },
+		"age":      func(t time.Time) string { return age(l, t) },
+		"metric":   metric,
+		"bytes":    formatBytes,
+		"verdict":  func(a *store.Analysis) store.Level { return a.Verdict() },
+		"time":     l.DateTime,
+		"chart":    func(points []store.SeriesPoint, name string) template.HTML { return chart(l, points, name) },
+		"deviceID": store.DeviceID,
+	}
+	parse := func(page string) *template.Template {
+		return template.Must(template.New("layout.html").Funcs(funcs).ParseFS(templatesFS, "templates/layout.html", "templates/"+page))
+	}
+	return pages{l: l, index: parse("index.html"), device: parse("device.html")}
+}
+
+// Register регистрирует веб-интерфейс на языке lang. Доступ — по HTTP Basic,
+// пароль — token (имя пользователя любое).
+func Register(mux *http.ServeMux, token string, st *store.Store, lang i18n.Lang) {
+	static, _ := fs.Sub(staticFS, "static")
+	mux.Handle("GET /ui/static/", http.StripPrefix("/ui/static/", http.FileServerFS(static)))
+
+	p := newPages(lang.Or(i18n.Default))
+	mux.Handle("GET /{$}", basicAuth(token, handleIndex(st, p)))
+	mux.Handle("GET /ui/hosts/{host}/devices/{device}", basicAuth(token, handleDevice(st, p)))
+}
+
+func basicAuth(token string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, pass, ok := r.BasicAuth()
+		if token == "" || !ok || subtle.ConstantTimeCompare([]byte(pass), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="smart-control", charset="UTF-8"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+type hostView struct {
+	store.Host
+	Devices []deviceView
+}
+
+// deviceView — устройство с уровнем по состоянию оповещений, как в боте и сводке
+type deviceView struct {
+	store.DeviceSummary
+	Level store.Level
+}
+
+func handleIndex(st *store.Store, p pages) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		hosts, err := st.Hosts()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		counts := map[store.Level]int{}
+		views := make([]hostView, 0, len(hosts))
+		for _, h := range hosts {
+			devices, err := st.DeviceSummaries(h)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			view := hostView{Host: h}
+			for _, d := range devices {
+				level, _ := h.DeviceLevel(d)
+				counts[level]++
+				view.Devices = append(view.Devices, deviceView{DeviceSummary: d, Level: level})
+			}
+			views = append(views, view)
+		}
+		render(w, p.index, map[string]any{
+			"Title":  p.l.T("web.disks"),
+			"Hosts":  views,
+			"Counts": counts,
+			"Levels": []store.Level{store.LevelCritical, store.LevelWarning, store.LevelOK, store.LevelUnknown},
+		})
+	}
+}
+
+func handleDevice(st *store.Store, p pages) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		host := r.PathValue("host")
+		device, ok, err := st.FindDevice(host, r.PathValue("device"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		history, err := st.History(host, device, time.Time{}, time.Time{})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		var latest store.Snapshot
+		if len(history) > 0 {
+			latest = history[0]
+		}
+		h, _, err := st.Host(host)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		summary := store.NewDeviceSummary(device, latest)
+		level, _ := h.DeviceLevel(summary)
+
+		type chartView struct {
+			Title  string
+			Name   string
+			Points []store.SeriesPoint
+		}
+		var charts []chartView
+		for _, name := range chartMetrics {
+			points := store.Series(history, name)[name]
+			if len(points) > 0 {
+				charts = append(charts, chartView{Title: p.l.T("web.metric." + name), Name: name, Points: points})
+			}
+		}
+
+		render(w, p.device, map[string]any{
+			"Title":   host + " " + device,
+			"Host":    host,
+			"Summary": summary,
+			"Level":   level,
+			"Latest":  latest,
+			"Charts":  charts,
+			"History": history[:min(len(history), historyLimit)],
+		})
+	}
+}
+
+func render(w http.ResponseWriter, t *template.Template, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := t.Execute(w, data); err != nil {
+		slog.Error("render template", "template", t.Name(), "err", err)
+	}
+}
+
+// age — «сколько времени назад» в коротком виде на языке l
+func age(l i18n.Lang, t time.Time) string {
+	if t.IsZero() {
+		return "—"
+	}
+	d := time.Since(t)
+	switch {
+	case d < time.Minute:
+		return l.T("web.age.now")
+	case d < time.Hour:
+		return l.Sprintf("web.age.minutes", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return l.Sprintf("web.age.hours", int(d.Hours()))
+	}
+	return l.Sprintf("web.age.days", int(d.Hours()/24))
+}
+
+// metric форматирует показатель из набора values, "—" если его нет
+func metric(values map[string]float64, name string) string {
+	v, ok := values[name]
+	if !ok {
+		return "—"
+	}
+	if name == smartdata.MetricBytesWritten || name == smartdata.MetricBytesRead || name == smartdata.MetricCapacityBytes {
+		return formatBytes(v)
+	}
+	if v == math.Trunc(v) {
+		return fmt.Sprintf("%.0f", v)
+	}
+	return fmt.Sprintf("%.1f", v)
+}
+
+func formatBytes(v float64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB", "PB"}
+	i := 0
+	for v >= 1000 && i < len(units)-1 {
+		v /= 1000
+		i++
+	}
+	return fmt.Sprintf("%.2f %s", v, units[i])
+}
@@ -94,6 +94,7 @@ func main() {
 		sms.ApiURL = section.Key("apiUrl").String()
 		sms.Token = section.Key("token").String()
 		sms.Cron = section.Key("cron").String()
+		sms.Labels = section.Key("labels").String()
 	}
 
 	sch, err := cron.NewCronScheduleFromString(sms.Cron)
@@ -7,6 +7,7 @@ import (
 	"context"
 	"fmt"
 	"smart-control-win/internal/cron"
+	"strings"
 	"time"
 
 	"golang.org/x/sys/windows/svc"
@@ -17,6 +18,7 @@ type smartService struct {
 	ApiURL      string `json:"apiUrl"`
 	Token       string `json:"token"`
 	Cron        string `json:"cron"`
+	Labels      string `json:"labels"`
 	schedule    *cron.CronSchedule
 	programData string
 }
@@ -54,6 +56,12 @@ loop:
 
 func (m *smartService) collectAndSaveSMARTData() {
 	report := smartReportOnAllDevices(m.Hostname, m.programData)
+	report.CronSchedule = m.Cron
+	for _, l := range strings.Split(m.Labels, ",") {
+		if l = strings.TrimSpace(l); l != "" {
+			report.Labels = append(report.Labels, l)
+		}
+	}
 	if err := sendReport(context.Background(), m.ApiURL, m.Token, report); err != nil {
 		logErrorf("collectAndSaveSMARTData завершилась с ошибкой: %v", err)
 		return
@@ -68,11 +68,13 @@ func smartReportOnAllDevices(hostname, programData string) CommonSMARTReport {
 		Hostname:  hostname,
 		OS:        "windows",
 		Timestamp: time.Now(),
+
+		AgentVersion: agentVersion,
 	}
 
 	devices, err := getSmartDevices(programData)
 	if err != nil {
-		report.RawError = fmt.Sprintf("Ошибка получения списка дисков: %s", err.Error())
+		report.ErrorCode, report.RawError = ErrCodeListDevices, err.Error()
 		logErrorf("getSmartDevices error: %v", err)
 		return report
 	}
@@ -82,7 +84,8 @@ func smartReportOnAllDevices(hostname, programData string) CommonSMARTReport {
 			result, err := runSmartctlCommands(programData, device)
 			if err != nil {
 				logErrorf("smartctl error for device %q: %v\n%s", device, err, result)
-				device.RawError = fmt.Sprintf("Ошибка анализа %s(%s):\n%s\n%s", device.Device, device.Type, err.Error(), result)
+				device.ErrorCode = ErrCodeSmartctl
+				device.RawError = fmt.Sprintf("%s (%s): %s\n%s", device.Device, device.Type, err.Error(), result)
 			} else {
 				logEvent("smartctl analysis done for device %q", device)
 				device.SMARTData = result